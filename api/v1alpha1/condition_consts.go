@@ -16,6 +16,13 @@ limitations under the License.
 
 package v1alpha1
 
+const (
+	// CABundleValidCondition reports whether the CA bundle referenced by an InfobloxInstance can be used and is not about to expire.
+	CABundleValidCondition = "CABundleValid"
+	// AddressesAvailableCondition reports whether addresses could be allocated from the subnets of a pool.
+	AddressesAvailableCondition = "AddressesAvailable"
+)
+
 const (
 	// ReadyReason is a generic Reason for the Ready condition to be true.
 	ReadyReason = "Ready"
@@ -26,6 +33,14 @@ const (
 	AddressAllocatedReason = "AddressAllocated"
 	// AllocationFailedReason indicates that the allocation of an IP address from the InfobloxIPPool has failed.
 	AllocationFailedReason = "AllocationFailed"
+	// SubnetExhaustedReason indicates that no address could be allocated because all subnets of the pool are exhausted.
+	SubnetExhaustedReason = "SubnetExhausted"
+	// AddressesAvailableReason indicates that addresses could be allocated from a pool.
+	AddressesAvailableReason = "AddressesAvailable"
+	// NamespaceNotAllowedReason indicates that the namespace of a claim is not allowed to use the referenced pool or its InfobloxInstance.
+	NamespaceNotAllowedReason = "NamespaceNotAllowed"
+	// QuotaExceededReason indicates that the namespace or Cluster of a claim already holds as many addresses as the quota of the pool allows.
+	QuotaExceededReason = "QuotaExceeded"
 
 	// AuthenticationFailedReason indicates that the credentials provided to Infoblox were invalid.
 	AuthenticationFailedReason = "AuthenticationFailed"
@@ -35,6 +50,25 @@ const (
 	DNSViewNotFoundReason = "DNSViewNotFound"
 	// NetworkNotFoundReason indicates that the specified network could not be found on the Infoblox instance.
 	NetworkNotFoundReason = "NetworkNotFound"
+	// InstanceNotPermittedReason indicates that the namespace of an InfobloxIPPool is not allowed to use the referenced InfobloxInstance.
+	InstanceNotPermittedReason = "InstanceNotPermitted"
+	// NetworkAllocationFailedReason indicates that a network could not be allocated from the network container of an InfobloxIPPool.
+	NetworkAllocationFailedReason = "NetworkAllocationFailed"
+	// CABundleValidReason indicates that the CA bundle of an InfobloxInstance is valid.
+	CABundleValidReason = "CABundleValid"
+	// CABundleInvalidReason indicates that the CA bundle of an InfobloxInstance could not be loaded or does not contain any certificates.
+	CABundleInvalidReason = "CABundleInvalid"
+	// CABundleExpiringSoonReason indicates that a certificate in the CA bundle of an InfobloxInstance is about to expire.
+	CABundleExpiringSoonReason = "CABundleExpiringSoon"
+	// CABundleExpiredReason indicates that a certificate in the CA bundle of an InfobloxInstance has expired.
+	CABundleExpiredReason = "CABundleExpired"
+	// InstanceUnreachableReason indicates that the health check of an InfobloxInstance failed, or that the InfobloxInstance of a pool is unreachable.
+	InstanceUnreachableReason = "InstanceUnreachable"
+	// WAPIVersionUnsupportedReason indicates that the grid of an InfobloxInstance does not support the configured WAPI version.
+	WAPIVersionUnsupportedReason = "WAPIVersionUnsupported"
 	// ConfigurationValidReason indicates that the configuration of the InfobloxInstance has been validated successfully.
 	ConfigurationValidReason = "ConfigurationValid"
+	// DryRunReason indicates that an address or network has not been allocated because the provider runs in dry-run mode.
+	// The message describes the change that would have been made.
+	DryRunReason = "DryRun"
 )
@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GlobalInfobloxIPPool is the Schema for the GlobalInfobloxIPPools API.
+// Unlike InfobloxIPPool it is cluster-scoped and can be referenced by claims in any namespace.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Network view",type="string",JSONPath=".spec.networkView",description="Default network view"
+// +kubebuilder:printcolumn:name="Subnets",type="string",JSONPath=".spec.subnets",description="Subnets to allocate IPs from"
+// +kubebuilder:printcolumn:name="DNSZone",type="string",JSONPath=".spec.dnsZone",description="The DNS zone within which hostnames will be allocated"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="Deleted",type=date,JSONPath=`.metadata.deletionTimestamp`,priority=1
+type GlobalInfobloxIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InfobloxIPPoolSpec   `json:"spec,omitempty"`
+	Status InfobloxIPPoolStatus `json:"status,omitempty"`
+}
+
+// GlobalInfobloxIPPoolList contains a list of GlobalInfobloxIPPool.
+//
+// +kubebuilder:object:root=true
+type GlobalInfobloxIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GlobalInfobloxIPPool `json:"items"`
+}
+
+// GetConditions returns pool conditions.
+func (i *GlobalInfobloxIPPool) GetConditions() []metav1.Condition {
+	return i.Status.Conditions
+}
+
+// SetConditions sets pool conditions.
+func (i *GlobalInfobloxIPPool) SetConditions(conditions []metav1.Condition) {
+	i.Status.Conditions = conditions
+}
+
+// PoolSpec implements the GenericInfobloxPool interface.
+func (i *GlobalInfobloxIPPool) PoolSpec() *InfobloxIPPoolSpec {
+	return &i.Spec
+}
+
+// PoolStatus implements the GenericInfobloxPool interface.
+func (i *GlobalInfobloxIPPool) PoolStatus() *InfobloxIPPoolStatus {
+	return &i.Status
+}
+
+func init() {
+	SchemeBuilder.Register(&GlobalInfobloxIPPool{}, &GlobalInfobloxIPPoolList{})
+}
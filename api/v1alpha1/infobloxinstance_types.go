@@ -34,17 +34,43 @@ type InfobloxInstanceSpec struct {
 	// +kubebuilder:default="443"
 	Port string `json:"port,omitzero"`
 
+	// FailoverEndpoints are further members of the grid that serve the WAPI, e.g. the grid master candidate.
+	// Requests are sent to the next endpoint if the current one is unreachable or unavailable.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=8
+	// +listType=atomic
+	FailoverEndpoints []Endpoint `json:"failoverEndpoints,omitempty"`
+
+	// PathPrefix is prepended to the WAPI path, e.g. if the Infoblox instance is served behind a reverse proxy.
+	//
+	// +kubebuilder:validation:Optional
+	PathPrefix string `json:"pathPrefix,omitzero"`
+
+	// Proxy configures the HTTP(S) proxy used to connect to the Infoblox instance.
+	// The HTTPS_PROXY and NO_PROXY environment variables of the provider are used if unset.
+	//
+	// +kubebuilder:validation:Optional
+	Proxy ProxyConfig `json:"proxy,omitzero"`
+
 	// WAPIVersion is the version of the Infoblox Web-based Application Programming Interface (WAPI) endoint.
+	// It must be supported by the grid. If unset, the newest version supported by the grid is used.
 	//
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
 	WAPIVersion string `json:"wapiVersion,omitzero"`
 
 	// CredentialsSecretRef is a reference to a secret containing the username and password to be used for authentication.
 	// Both `username`/`password` and `clientCert`/`clientKey` are supported and one of either combination is required to be present as keys in the secret.
+	// Required if the credentials source is Secret.
 	//
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
 	CredentialsSecretRef CredentialsReferece `json:"credentialsSecretRef,omitzero"`
 
+	// CredentialsSource selects where the credentials are loaded from. Defaults to the secret referenced by CredentialsSecretRef.
+	//
+	// +kubebuilder:validation:Optional
+	CredentialsSource CredentialsSource `json:"credentialsSource,omitzero"`
+
 	// DefaultNetworkView is the default network view used when interacting with Infoblox.
 	// InfobloxIPPools will inherit this value when not explicitly specifying a network view.
 	//
@@ -62,11 +88,199 @@ type InfobloxInstanceSpec struct {
 	// +kubebuilder:validation:Optional
 	DisableTLSVerification bool `json:"disableTLSVerification,omitzero"`
 
+	// AllowedNamespaces restricts the namespaces whose pools and claims may use this instance.
+	// All namespaces are allowed if unset.
+	//
+	// +kubebuilder:validation:Optional
+	AllowedNamespaces *metav1.LabelSelector `json:"allowedNamespaces,omitempty"`
+
 	// CustomCAPath can be used to point Infoblox client to a file with a list of accepted certificate authorities.
 	// Only used if DisableTLSVerification is set to 'false'.
 	//
 	// +kubebuilder:validation:Optional
 	CustomCAPath string `json:"customCAPath,omitzero"`
+
+	// RateLimit limits the requests sent to the Infoblox instance by all pools and claims using it.
+	// Requests are not limited if unset.
+	//
+	// +kubebuilder:validation:Optional
+	RateLimit RateLimitConfig `json:"rateLimit,omitzero"`
+
+	// RequestTimeout is the timeout of a single request to the Infoblox instance. Defaults to 30s.
+	//
+	// +kubebuilder:validation:Optional
+	RequestTimeout metav1.Duration `json:"requestTimeout,omitzero"`
+
+	// Retry configures how requests that are throttled or fail with a server error are retried.
+	//
+	// +kubebuilder:validation:Optional
+	Retry RetryConfig `json:"retry,omitzero"`
+
+	// CABundleRef references a key of a ConfigMap or Secret containing PEM encoded certificate authorities
+	// that are accepted for the Infoblox instance. Can't be combined with CustomCAPath or DisableTLSVerification.
+	//
+	// +kubebuilder:validation:Optional
+	CABundleRef CABundleReference `json:"caBundleRef,omitzero"`
+}
+
+const (
+	// CABundleKindConfigMap is the kind of a CA bundle stored in a ConfigMap.
+	CABundleKindConfigMap = "ConfigMap"
+	// CABundleKindSecret is the kind of a CA bundle stored in a Secret.
+	CABundleKindSecret = "Secret"
+	// DefaultCABundleKey is the key used if a CABundleReference doesn't specify one.
+	DefaultCABundleKey = "ca.crt"
+)
+
+// CABundleReference is a reference to a key of a ConfigMap or Secret containing PEM encoded certificate authorities.
+type CABundleReference struct {
+
+	// Kind of the referenced object.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind,omitzero"`
+
+	// Name of the referenced object.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name,omitzero"`
+
+	// Namespace of the referenced object. Defaults to the namespace of the provider.
+	//
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitzero"`
+
+	// Key of the referenced object that contains the certificate authorities.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="ca.crt"
+	Key string `json:"key,omitzero"`
+}
+
+// Endpoint is a further endpoint of an Infoblox grid.
+type Endpoint struct {
+
+	// Host of the endpoint.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength:=1
+	Host string `json:"host,omitzero"`
+
+	// Port of the endpoint. Defaults to the port of the instance.
+	//
+	// +kubebuilder:validation:Optional
+	Port string `json:"port,omitzero"`
+}
+
+// RateLimitConfig limits the rate of requests.
+type RateLimitConfig struct {
+
+	// QPS is the number of requests per second.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	QPS int32 `json:"qps,omitzero"`
+
+	// Burst is the number of requests that can be sent at once. Defaults to QPS.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Burst int32 `json:"burst,omitzero"`
+}
+
+// RetryConfig configures how requests are retried with exponential backoff.
+// Throttled requests (429) and requests to an unavailable grid (503) are always retried.
+// Other server errors are only retried for requests that don't modify objects.
+type RetryConfig struct {
+
+	// MaxRetries is the number of times a request is retried. Defaults to 3, 0 disables retries.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+
+	// InitialBackoff is the delay before the first retry. It is doubled for every further retry. Defaults to 500ms.
+	//
+	// +kubebuilder:validation:Optional
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitzero"`
+
+	// MaxBackoff is the maximum delay between retries, including delays requested by the grid. Defaults to 10s.
+	//
+	// +kubebuilder:validation:Optional
+	MaxBackoff metav1.Duration `json:"maxBackoff,omitzero"`
+}
+
+// ProxyConfig configures a proxy.
+type ProxyConfig struct {
+
+	// URL of the proxy, e.g. http://proxy.example.com:3128.
+	//
+	// +kubebuilder:validation:Required
+	URL string `json:"url,omitzero"`
+
+	// NoProxy is a list of hosts, domains, IP addresses and CIDRs that are connected to directly.
+	//
+	// +kubebuilder:validation:Optional
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
+const (
+	// CredentialsSourceTypeSecret loads the credentials from the secret referenced by CredentialsSecretRef.
+	CredentialsSourceTypeSecret = "Secret"
+	// CredentialsSourceTypeFile loads the credentials from files mounted into the provider.
+	CredentialsSourceTypeFile = "File"
+	// CredentialsSourceTypeTokenExchange fetches the credentials from a local HTTP endpoint, e.g. a Vault agent sidecar.
+	CredentialsSourceTypeTokenExchange = "TokenExchange"
+)
+
+// CredentialsSource configures where the credentials for an Infoblox instance are loaded from.
+type CredentialsSource struct {
+
+	// Type of the credentials source.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Secret;File;TokenExchange
+	// +kubebuilder:default=Secret
+	Type string `json:"type,omitzero"`
+
+	// File configures the File credentials source.
+	//
+	// +kubebuilder:validation:Optional
+	File FileCredentialsSource `json:"file,omitzero"`
+
+	// TokenExchange configures the TokenExchange credentials source.
+	//
+	// +kubebuilder:validation:Optional
+	TokenExchange TokenExchangeCredentialsSource `json:"tokenExchange,omitzero"`
+}
+
+// UsesSecret returns whether the credentials are loaded from the secret referenced by the InfobloxInstance.
+func (s CredentialsSource) UsesSecret() bool {
+	return s.Type == "" || s.Type == CredentialsSourceTypeSecret
+}
+
+// FileCredentialsSource loads the credentials from a directory in the provider's file system.
+type FileCredentialsSource struct {
+
+	// Path of the directory containing the credentials. It uses the same file names as the keys of a credentials secret,
+	// i.e. `username`/`password` or `clientCert`/`clientKey`. Changed files are picked up for the next request.
+	//
+	// +kubebuilder:validation:Required
+	Path string `json:"path,omitzero"`
+}
+
+// TokenExchangeCredentialsSource fetches the credentials from a local HTTP endpoint.
+type TokenExchangeCredentialsSource struct {
+
+	// URL of the endpoint. It must return a JSON object with a `username` and `password`, either at the top level or below `data`
+	// like Vault does. The endpoint must be on a loopback address or a Service in the cluster, and plain HTTP is only allowed
+	// for loopback addresses.
+	//
+	// +kubebuilder:validation:Required
+	URL string `json:"url,omitzero"`
 }
 
 // CredentialsReferece is a reference to a secret containing the Infoblox instance credentials.
@@ -77,12 +291,57 @@ type CredentialsReferece struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength:=1
 	Name string `json:"name,omitzero"`
+
+	// Namespace of the referenced secret. Defaults to the namespace of the provider.
+	// Only users that are allowed to read secrets in this namespace can reference it.
+	//
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitzero"`
 }
 
 // InfobloxInstanceStatus defines the observed state of InfobloxInstance.
 type InfobloxInstanceStatus struct {
 	// +kubebuilder:validation:Optional
 	Conditions []metav1.Condition `json:"conditions,omitzero"`
+
+	// LastContactTime is the time of the last successful health check.
+	//
+	// +kubebuilder:validation:Optional
+	LastContactTime metav1.Time `json:"lastContactTime,omitzero"`
+
+	// Latency is the duration of the last successful health check.
+	//
+	// +kubebuilder:validation:Optional
+	Latency metav1.Duration `json:"latency,omitzero"`
+
+	// GridName is the name of the grid the instance belongs to.
+	//
+	// +kubebuilder:validation:Optional
+	GridName string `json:"gridName,omitzero"`
+
+	// NIOSVersion is the version of NIOS running on the grid.
+	// It is only reported if the user is allowed to read the upgrade status of the grid.
+	//
+	// +kubebuilder:validation:Optional
+	NIOSVersion string `json:"niosVersion,omitzero"`
+
+	// WAPIVersion is the WAPI version used to talk to the grid. It is the configured version or,
+	// if none is configured, the newest version supported by the grid.
+	//
+	// +kubebuilder:validation:Optional
+	WAPIVersion string `json:"wapiVersion,omitzero"`
+
+	// ActiveEndpoint is the endpoint that answered the last health check, in the form host:port.
+	// Pools and claims send their requests to this endpoint first.
+	//
+	// +kubebuilder:validation:Optional
+	ActiveEndpoint string `json:"activeEndpoint,omitzero"`
+
+	// Features are the optional features supported by the grid with the used WAPI version.
+	// Pools and claims that need a missing feature fail with a descriptive error.
+	//
+	// +kubebuilder:validation:Optional
+	Features []string `json:"features,omitempty"`
 }
 
 // InfobloxInstance is the Schema for the infobloxinstances API.
@@ -92,7 +351,8 @@ type InfobloxInstanceStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host",description="Infoblox host's address"
 // +kubebuilder:printcolumn:name="Port",type="string",JSONPath=".spec.port",description="Networking port of the Infoblox host"
-// +kubebuilder:printcolumn:name="WAPI ver.",type="string",JSONPath=".spec.wapiVersion",description="Version of web API to be used"
+// +kubebuilder:printcolumn:name="WAPI ver.",type="string",JSONPath=".status.wapiVersion",description="Version of web API used"
+// +kubebuilder:printcolumn:name="Last Contact",type="date",JSONPath=".status.lastContactTime",description="Time of the last successful health check"
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:printcolumn:name="Deleted",type=date,JSONPath=`.metadata.deletionTimestamp`,priority=1
 type InfobloxInstance struct {
@@ -2,8 +2,19 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// GenericInfobloxPool is a common interface for InfobloxIPPool and GlobalInfobloxIPPool.
+// +kubebuilder:object:generate=false
+type GenericInfobloxPool interface {
+	client.Object
+	GetConditions() []metav1.Condition
+	SetConditions([]metav1.Condition)
+	PoolSpec() *InfobloxIPPoolSpec
+	PoolStatus() *InfobloxIPPoolStatus
+}
+
 // InfobloxIPPoolSpec defines the desired state of InfobloxIPPool.
 type InfobloxIPPoolSpec struct {
 
@@ -13,11 +24,18 @@ type InfobloxIPPoolSpec struct {
 	InstanceRef InstanceReference `json:"instance,omitzero"`
 
 	// Subnets is the subnet to assign IP addresses from.
-	// Can be omitted if addresses or first, last and prefix are set.
+	// Can be omitted if networkContainer is set.
 	//
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
 	Subnets []Subnet `json:"subnets,omitzero"`
 
+	// NetworkContainer configures the pool to create its own network in an Infoblox network container
+	// instead of allocating from existing subnets. The network is deleted when the pool is deleted.
+	// Mutually exclusive with subnets.
+	//
+	// +kubebuilder:validation:Optional
+	NetworkContainer NetworkContainer `json:"networkContainer,omitzero"`
+
 	// NetworkView defines Infoblox netwok view to be used with pool.
 	//
 	// +kubebuilder:validation:Optional
@@ -32,6 +50,118 @@ type InfobloxIPPoolSpec struct {
 	//
 	// +kubebuilder:validation:Optional
 	DNSZone string `json:"dnsZone,omitzero"`
+
+	// AllowedNamespaces restricts the namespaces whose claims may use this pool.
+	// Only supported on GlobalInfobloxIPPool. All namespaces are allowed if unset.
+	//
+	// +kubebuilder:validation:Optional
+	AllowedNamespaces *metav1.LabelSelector `json:"allowedNamespaces,omitempty"`
+
+	// Quota limits how many addresses a single namespace or Cluster may hold from this pool.
+	//
+	// +kubebuilder:validation:Optional
+	Quota Quota `json:"quota,omitzero"`
+
+	// Retention keeps the addresses of deleted claims, so a new claim for the same hostname receives the same address.
+	//
+	// +kubebuilder:validation:Optional
+	Retention Retention `json:"retention,omitzero"`
+
+	// Quarantine reserves released addresses for a period, so they aren't allocated again while stale ARP caches
+	// and DNS records still point to the previous host.
+	//
+	// +kubebuilder:validation:Optional
+	Quarantine Quarantine `json:"quarantine,omitzero"`
+}
+
+// Quarantine configures how long released addresses are reserved before they can be allocated again.
+type Quarantine struct {
+
+	// Period for which a released address is reserved in Infoblox. Only IPv4 addresses are reserved.
+	// Addresses can be allocated again immediately if unset.
+	//
+	// +kubebuilder:validation:Required
+	Period metav1.Duration `json:"period,omitzero"`
+}
+
+// Retention configures how long the addresses of deleted claims are kept.
+type Retention struct {
+
+	// Period for which the host record of a deleted claim is kept in Infoblox. A claim for the same hostname
+	// created within this period receives the same address. Addresses are released immediately if unset.
+	//
+	// +kubebuilder:validation:Required
+	Period metav1.Duration `json:"period,omitzero"`
+}
+
+// RetainedAddress is the address of a deleted claim that is kept for a new claim of the same hostname.
+type RetainedAddress struct {
+
+	// Hostname of the deleted claim.
+	//
+	// +kubebuilder:validation:Required
+	Hostname string `json:"hostname,omitzero"`
+
+	// Address that is kept.
+	//
+	// +kubebuilder:validation:Required
+	Address string `json:"address,omitzero"`
+
+	// Subnet the address has been allocated from.
+	//
+	// +kubebuilder:validation:Required
+	Subnet string `json:"subnet,omitzero"`
+
+	// HostRecordRef is the WAPI reference of the host record of the address.
+	//
+	// +kubebuilder:validation:Required
+	HostRecordRef string `json:"hostRecordRef,omitzero"`
+
+	// ExpirationTime is the time at which the address is released.
+	//
+	// +kubebuilder:validation:Required
+	ExpirationTime metav1.Time `json:"expirationTime,omitzero"`
+}
+
+// Quota limits the number of addresses that can be allocated from a pool. A limit of 0 means unlimited.
+type Quota struct {
+
+	// PerNamespace is the maximum number of addresses the claims of a single namespace may hold.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	PerNamespace int32 `json:"perNamespace,omitzero"`
+
+	// PerCluster is the maximum number of addresses the claims of a single Cluster may hold.
+	// Claims are attributed to a Cluster by their cluster.x-k8s.io/cluster-name label.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	PerCluster int32 `json:"perCluster,omitzero"`
+}
+
+// QuotaUsage reports the number of addresses held from a pool by a namespace or Cluster.
+type QuotaUsage struct {
+
+	// Namespace the usage is reported for.
+	//
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace,omitzero"`
+
+	// Cluster the usage is reported for. Empty if the usage is reported for the whole namespace.
+	//
+	// +kubebuilder:validation:Optional
+	Cluster string `json:"cluster,omitzero"`
+
+	// Used is the number of addresses that are currently allocated.
+	//
+	// +kubebuilder:validation:Required
+	Used int32 `json:"used"`
+
+	// Limit is the maximum number of addresses that can be allocated.
+	//
+	// +kubebuilder:validation:Required
+	Limit int32 `json:"limit"`
 }
 
 // InstanceReference is a reference to an infoblox instance resource.
@@ -44,10 +174,40 @@ type InstanceReference struct {
 	Name string `json:"name,omitzero"`
 }
 
+// NetworkContainer references an Infoblox network container to create a network in.
+type NetworkContainer struct {
+	// CIDR of the network container.
+	//
+	// +kubebuilder:validation:Required
+	CIDR string `json:"cidr,omitzero"`
+
+	// PrefixLength of the network to create in the network container.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	PrefixLength int32 `json:"prefixLength,omitzero"`
+}
+
 // InfobloxIPPoolStatus defines the observed state of InfobloxIPPool.
 type InfobloxIPPoolStatus struct {
 	// +kubebuilder:validation:Optional
 	Conditions []metav1.Condition `json:"conditions,omitzero"`
+
+	// NetworkContainerSubnet is the network that has been created in the network container for this pool.
+	//
+	// +kubebuilder:validation:Optional
+	NetworkContainerSubnet Subnet `json:"networkContainerSubnet,omitzero"`
+
+	// QuotaUsage reports the addresses held per namespace and Cluster if a quota is configured.
+	//
+	// +kubebuilder:validation:Optional
+	QuotaUsage []QuotaUsage `json:"quotaUsage,omitempty"`
+
+	// RetainedAddresses are the addresses of deleted claims that are kept until their expiration time.
+	//
+	// +kubebuilder:validation:Optional
+	RetainedAddresses []RetainedAddress `json:"retainedAddresses,omitempty"`
 }
 
 // Subnet defines the CIDR and Gateway.
@@ -100,6 +260,16 @@ func (i *InfobloxIPPool) SetConditions(conditions []metav1.Condition) {
 	i.Status.Conditions = conditions
 }
 
+// PoolSpec implements the GenericInfobloxPool interface.
+func (i *InfobloxIPPool) PoolSpec() *InfobloxIPPoolSpec {
+	return &i.Spec
+}
+
+// PoolStatus implements the GenericInfobloxPool interface.
+func (i *InfobloxIPPool) PoolStatus() *InfobloxIPPoolStatus {
+	return &i.Status
+}
+
 func init() {
 	SchemeBuilder.Register(&InfobloxIPPool{}, &InfobloxIPPoolList{})
 }
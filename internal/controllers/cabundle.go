@@ -0,0 +1,176 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// errCABundleKeyMissing is returned if the referenced CA bundle doesn't contain the expected key.
+var errCABundleKeyMissing = errors.New("CA bundle key missing")
+
+// caExpiryWarningPeriod is the time before the expiry of a CA certificate at which the CABundleValid condition turns false.
+const caExpiryWarningPeriod = 30 * 24 * time.Hour
+
+// loadCABundle returns the PEM encoded certificate authorities referenced by an InfobloxInstance.
+func loadCABundle(ctx context.Context, c client.Reader, ref v1alpha1.CABundleReference, operatorNamespace string) ([]byte, error) {
+	key := types.NamespacedName{Name: ref.Name, Namespace: caBundleNamespace(ref, operatorNamespace)}
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = v1alpha1.DefaultCABundleKey
+	}
+
+	var data []byte
+	switch ref.Kind {
+	case v1alpha1.CABundleKindConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, key, cm); err != nil {
+			return nil, fmt.Errorf("failed to fetch CA bundle config map: %w", err)
+		}
+		data = []byte(cm.Data[dataKey])
+		if len(data) == 0 {
+			data = cm.BinaryData[dataKey]
+		}
+	case v1alpha1.CABundleKindSecret:
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, key, secret); err != nil {
+			return nil, fmt.Errorf("failed to fetch CA bundle secret: %w", err)
+		}
+		data = secret.Data[dataKey]
+	default:
+		return nil, fmt.Errorf("unsupported CA bundle kind %q", ref.Kind)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: %s %s does not contain key %q", errCABundleKeyMissing, ref.Kind, key, dataKey)
+	}
+	return data, nil
+}
+
+// caBundleNamespace returns the namespace of a CA bundle, which defaults to the namespace of the provider.
+func caBundleNamespace(ref v1alpha1.CABundleReference, operatorNamespace string) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return operatorNamespace
+}
+
+// caBundleExpiry returns the certificate of a PEM encoded CA bundle that expires first.
+func caBundleExpiry(data []byte) (*x509.Certificate, error) {
+	var first *x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		if first == nil || cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	if first == nil {
+		return nil, errors.New("CA bundle does not contain any PEM encoded certificates")
+	}
+	return first, nil
+}
+
+// caBundleCondition returns the CABundleValid condition for a CA bundle and when it needs to be checked again.
+func caBundleCondition(data []byte, now time.Time) (metav1.Condition, time.Duration) {
+	cert, err := caBundleExpiry(data)
+	if err != nil {
+		return metav1.Condition{
+			Type:    v1alpha1.CABundleValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.CABundleInvalidReason,
+			Message: err.Error(),
+		}, 0
+	}
+
+	message := fmt.Sprintf("CA certificate %q expires at %s", cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339))
+	switch {
+	case now.After(cert.NotAfter):
+		return metav1.Condition{
+			Type:    v1alpha1.CABundleValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.CABundleExpiredReason,
+			Message: fmt.Sprintf("CA certificate %q expired at %s", cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339)),
+		}, 0
+	case now.Add(caExpiryWarningPeriod).After(cert.NotAfter):
+		return metav1.Condition{
+			Type:    v1alpha1.CABundleValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.CABundleExpiringSoonReason,
+			Message: message,
+		}, cert.NotAfter.Sub(now)
+	default:
+		return metav1.Condition{
+			Type:    v1alpha1.CABundleValidCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.CABundleValidReason,
+			Message: message,
+		}, cert.NotAfter.Add(-caExpiryWarningPeriod).Sub(now)
+	}
+}
+
+// instancesForCABundle returns a mapper that enqueues all InfobloxInstances referencing a ConfigMap or Secret as CA bundle.
+// Secrets are mapped by instancesForSecret, since they can also hold credentials.
+func instancesForCABundle(c client.Client, kind, operatorNamespace string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		instances := &v1alpha1.InfobloxInstanceList{}
+		if err := c.List(ctx, instances); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, instance := range instances.Items {
+			if instanceReferencesCABundle(&instance, kind, client.ObjectKeyFromObject(o), operatorNamespace) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name}})
+			}
+		}
+		return requests
+	}
+}
+
+// configMapReferencedByInstance returns a predicate that only accepts ConfigMaps referenced by an InfobloxInstance as CA bundle,
+// so changes to unrelated ConfigMaps in the cluster don't need to be mapped.
+func configMapReferencedByInstance(ctx context.Context, c client.Reader, operatorNamespace string) predicate.Predicate {
+	return referencedByInstance(ctx, c, func(instance *v1alpha1.InfobloxInstance, key types.NamespacedName) bool {
+		return instanceReferencesCABundle(instance, v1alpha1.CABundleKindConfigMap, key, operatorNamespace)
+	})
+}
+
+// instanceReferencesCABundle returns whether an InfobloxInstance references the ConfigMap or Secret with the given key as CA bundle.
+func instanceReferencesCABundle(instance *v1alpha1.InfobloxInstance, kind string, key types.NamespacedName, operatorNamespace string) bool {
+	ref := instance.Spec.CABundleRef
+	return ref.Kind == kind && ref.Name == key.Name && caBundleNamespace(ref, operatorNamespace) == key.Namespace
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// CredentialSources caches the credential sources of InfobloxInstances using a File or TokenExchange credentials source,
+// so sources that cache credentials don't need to fetch them again for every client. A single cache is shared by all
+// controllers. A nil cache creates a new source for every client.
+type CredentialSources struct {
+	mu      sync.Mutex
+	sources map[string]cachedCredentialSource
+}
+
+type cachedCredentialSource struct {
+	config v1alpha1.CredentialsSource
+	source infoblox.CredentialSource
+}
+
+// NewCredentialSources returns an empty credential source cache.
+func NewCredentialSources() *CredentialSources {
+	return &CredentialSources{sources: map[string]cachedCredentialSource{}}
+}
+
+// get returns the credential source of an InfobloxInstance using a File or TokenExchange credentials source.
+// The cached source is replaced if the credentials source of the instance changed.
+func (c *CredentialSources) get(instance *v1alpha1.InfobloxInstance) infoblox.CredentialSource {
+	config := instance.Spec.CredentialsSource
+	if c == nil {
+		return newCredentialSource(config)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.sources[instance.Name]; ok && cached.config == config {
+		return cached.source
+	}
+	cs := newCredentialSource(config)
+	c.sources[instance.Name] = cachedCredentialSource{config: config, source: cs}
+	return cs
+}
+
+// forget removes the credential source of an InfobloxInstance that has been deleted or uses a Secret now.
+func (c *CredentialSources) forget(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sources, name)
+}
+
+// newCredentialSource returns the credential source for a File or TokenExchange credentials source.
+func newCredentialSource(source v1alpha1.CredentialsSource) infoblox.CredentialSource {
+	if source.Type == v1alpha1.CredentialsSourceTypeFile {
+		return infoblox.NewFileCredentialSource(source.File.Path)
+	}
+	return infoblox.NewTokenExchangeCredentialSource(source.TokenExchange.URL)
+}
+
+// authConfigForInstance returns the configuration to authenticate against an InfobloxInstance.
+func authConfigForInstance(ctx context.Context, c client.Reader, instance *v1alpha1.InfobloxInstance, operatorNamespace string, sources *CredentialSources) (infoblox.AuthConfig, error) {
+	if !instance.Spec.CredentialsSource.UsesSecret() {
+		return infoblox.AuthConfig{Source: sources.get(instance)}, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, credentialsSecretKey(instance.Spec.CredentialsSecretRef, operatorNamespace), secret); err != nil {
+		return infoblox.AuthConfig{}, fmt.Errorf("failed to fetch secret: %w", err)
+	}
+
+	ac, err := infoblox.AuthConfigFromSecretData(secret.Data)
+	if err != nil {
+		return infoblox.AuthConfig{}, fmt.Errorf("credentials secret is invalid: %w", err)
+	}
+	return ac, nil
+}
+
+// credentialsSecretKey returns the key of the credentials secret of an InfobloxInstance.
+// The secret is looked up in the namespace of the provider unless the reference specifies a namespace.
+func credentialsSecretKey(ref v1alpha1.CredentialsReferece, operatorNamespace string) types.NamespacedName {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = operatorNamespace
+	}
+	return types.NamespacedName{Name: ref.Name, Namespace: namespace}
+}
+
+// instancesForSecret returns a mapper that enqueues all InfobloxInstances referencing a Secret, either for their credentials or as CA bundle.
+// This makes sure rotated credentials are validated and used without restarting the provider.
+func instancesForSecret(c client.Client, operatorNamespace string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		instances := &v1alpha1.InfobloxInstanceList{}
+		if err := c.List(ctx, instances); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, instance := range instances.Items {
+			if instanceReferencesSecret(&instance, client.ObjectKeyFromObject(o), operatorNamespace) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name}})
+			}
+		}
+		return requests
+	}
+}
+
+// secretReferencedByInstance returns a predicate that only accepts Secrets referenced by an InfobloxInstance,
+// so changes to unrelated Secrets in the cluster don't need to be mapped.
+func secretReferencedByInstance(ctx context.Context, c client.Reader, operatorNamespace string) predicate.Predicate {
+	return referencedByInstance(ctx, c, func(instance *v1alpha1.InfobloxInstance, key types.NamespacedName) bool {
+		return instanceReferencesSecret(instance, key, operatorNamespace)
+	})
+}
+
+// referencedByInstance returns a predicate that only accepts objects referenced by an InfobloxInstance. If the instances
+// can't be listed, the change is dropped, since the instances pick it up with their next health check anyway.
+func referencedByInstance(ctx context.Context, c client.Reader, references func(*v1alpha1.InfobloxInstance, types.NamespacedName) bool) predicate.Predicate {
+	logger := log.FromContext(ctx)
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		instances := &v1alpha1.InfobloxInstanceList{}
+		if err := c.List(ctx, instances); err != nil {
+			logger.Error(err, "failed to list instances referencing object", "object", client.ObjectKeyFromObject(o))
+			return false
+		}
+		for _, instance := range instances.Items {
+			if references(&instance, client.ObjectKeyFromObject(o)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// instanceReferencesSecret returns whether an InfobloxInstance references the Secret with the given key for its credentials or as CA bundle.
+func instanceReferencesSecret(instance *v1alpha1.InfobloxInstance, key types.NamespacedName, operatorNamespace string) bool {
+	if instance.Spec.CredentialsSource.UsesSecret() && credentialsSecretKey(instance.Spec.CredentialsSecretRef, operatorNamespace) == key {
+		return true
+	}
+	return instanceReferencesCABundle(instance, v1alpha1.CABundleKindSecret, key, operatorNamespace)
+}
+
+// instanceToPools returns a mapper that enqueues all pools of a kind that reference an InfobloxInstance,
+// so pools recover once the instance does, e.g. after its credentials have been rotated.
+func instanceToPools(c client.Client, newList func() client.ObjectList) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		list := newList()
+		if err := c.List(ctx, list); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		switch pools := list.(type) {
+		case *v1alpha1.InfobloxIPPoolList:
+			for _, pool := range pools.Items {
+				if pool.Spec.InstanceRef.Name == o.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pool)})
+				}
+			}
+		case *v1alpha1.GlobalInfobloxIPPoolList:
+			for _, pool := range pools.Items {
+				if pool.Spec.InstanceRef.Name == o.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pool)})
+				}
+			}
+		}
+		return requests
+	}
+}
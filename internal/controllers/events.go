@@ -0,0 +1,94 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+const (
+	// AddressReleasedEventReason is the reason of the Event recorded on a claim when its address has been released.
+	AddressReleasedEventReason = "AddressReleased"
+	// AddressRetainedEventReason is the reason of the Event recorded on a claim when its address is kept for the retention period of the pool.
+	AddressRetainedEventReason = "AddressRetained"
+	// QuarantineFailedEventReason is the reason of the Event recorded on a claim when its released address could not be quarantined.
+	QuarantineFailedEventReason = "QuarantineFailed"
+)
+
+// withChangeEvents returns a function that creates Infoblox clients which record an Event on obj
+// for every object they create, update or delete in Infoblox.
+func withChangeEvents(newClientFn func(infoblox.Config) (infoblox.Client, error), recorder record.EventRecorder, obj runtime.Object) func(infoblox.Config) (infoblox.Client, error) {
+	return func(config infoblox.Config) (infoblox.Client, error) {
+		config.OnChange = func(change infoblox.Change) {
+			reason, message := changeEvent(change)
+			recorder.Event(obj, corev1.EventTypeNormal, reason, message)
+		}
+		return newClientFn(config)
+	}
+}
+
+// changeEvent returns the reason and message of the Event for a change in Infoblox, e.g. HostRecordCreated.
+func changeEvent(change infoblox.Change) (string, string) {
+	kind, description := "Object", change.ObjectType
+	switch change.ObjectType {
+	case "record:host":
+		kind, description = "HostRecord", "host record"
+	case "network", "ipv6network":
+		kind, description = "Network", "network"
+	case "fixedaddress":
+		kind, description = "Reservation", "reservation"
+	}
+	if change.DryRun {
+		return v1alpha1.DryRunReason, fmt.Sprintf("Would %s %s %q (%s)", strings.ToLower(change.Operation), description, change.Name, change.Ref)
+	}
+	// The operations are verbs ending with e, e.g. Create.
+	verb := change.Operation + "d"
+	return kind + verb, fmt.Sprintf("%s %s %q (%s)", verb, description, change.Name, change.Ref)
+}
+
+// readyCondition returns a copy of the Ready condition of obj, or an empty condition if it is not set.
+func readyCondition(obj conditions.Getter) metav1.Condition {
+	return ptr.Deref(conditions.Get(obj, clusterv1.ReadyCondition), metav1.Condition{})
+}
+
+// recordReadyChange records an Event if the Ready condition of obj differs from the condition before the reconcile.
+// The Event is a warning unless the object became ready.
+func recordReadyChange(recorder record.EventRecorder, obj interface {
+	runtime.Object
+	conditions.Getter
+}, before metav1.Condition) {
+	after := readyCondition(obj)
+	if after.Status == "" || (after.Status == before.Status && after.Reason == before.Reason && after.Message == before.Message) {
+		return
+	}
+	eventType := corev1.EventTypeWarning
+	if after.Status == metav1.ConditionTrue {
+		eventType = corev1.EventTypeNormal
+	}
+	recorder.Event(obj, eventType, after.Reason, after.Message)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SubnetExhaustedRequeueInterval is the interval at which claims are retried while all subnets of their pool are exhausted.
+// Claims are retried earlier if the capacity of the pool changes.
+const SubnetExhaustedRequeueInterval = 5 * time.Minute
+
+// exhaustedClaims tracks the claims that could not be allocated because all subnets of their pool are exhausted,
+// so they are retried less often until the capacity of the pool changes.
+type exhaustedClaims struct {
+	mu sync.Mutex
+	// claims maps the key of a pool to the keys of its exhausted claims and the pool generation and time of their next retry.
+	claims map[types.NamespacedName]map[types.NamespacedName]exhaustedClaim
+}
+
+type exhaustedClaim struct {
+	poolGeneration int64
+	retryAt        time.Time
+}
+
+// add records that allocating an address for a claim failed because the pool is exhausted.
+func (e *exhaustedClaims) add(pool client.Object, claim types.NamespacedName, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.claims == nil {
+		e.claims = map[types.NamespacedName]map[types.NamespacedName]exhaustedClaim{}
+	}
+	poolKey := client.ObjectKeyFromObject(pool)
+	if e.claims[poolKey] == nil {
+		e.claims[poolKey] = map[types.NamespacedName]exhaustedClaim{}
+	}
+	e.claims[poolKey][claim] = exhaustedClaim{poolGeneration: pool.GetGeneration(), retryAt: now.Add(SubnetExhaustedRequeueInterval)}
+}
+
+// remove forgets a claim, e.g. because an address was allocated for it.
+func (e *exhaustedClaims) remove(pool client.Object, claim types.NamespacedName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.claims[client.ObjectKeyFromObject(pool)], claim)
+}
+
+// retryAfter returns how long a claim has to wait before allocating an address is attempted again.
+// Zero is returned if the claim is not exhausted or the pool changed since the last attempt.
+func (e *exhaustedClaims) retryAfter(pool client.Object, claim types.NamespacedName, now time.Time) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c, ok := e.claims[client.ObjectKeyFromObject(pool)][claim]
+	if !ok || c.poolGeneration != pool.GetGeneration() || !now.Before(c.retryAt) {
+		return 0
+	}
+	return c.retryAt.Sub(now)
+}
+
+// requestsForPool forgets the exhausted claims of a pool and returns requests to reconcile them.
+func (e *exhaustedClaims) requestsForPool(_ context.Context, pool client.Object) []reconcile.Request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	poolKey := client.ObjectKeyFromObject(pool)
+	requests := make([]reconcile.Request, 0, len(e.claims[poolKey]))
+	for claim := range e.claims[poolKey] {
+		requests = append(requests, reconcile.Request{NamespacedName: claim})
+	}
+	delete(e.claims, poolKey)
+	return requests
+}
+
+// poolCapacityChanged is a predicate for pool updates that might allow exhausted claims to be allocated,
+// i.e. changes to the spec or addresses becoming available again.
+var poolCapacityChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() {
+			return true
+		}
+		oldPool, okOld := e.ObjectOld.(conditions.Getter)
+		newPool, okNew := e.ObjectNew.(conditions.Getter)
+		return okOld && okNew &&
+			!conditions.IsTrue(oldPool, v1alpha1.AddressesAvailableCondition) &&
+			conditions.IsTrue(newPool, v1alpha1.AddressesAvailableCondition)
+	},
+}
+
+// isSubnetExhausted returns whether all allocation attempts failed because the subnets are exhausted.
+func isSubnetExhausted(errs []error) bool {
+	if len(errs) == 0 {
+		return false
+	}
+	for _, err := range errs {
+		if !errors.Is(err, infoblox.ErrRangeExhausted) {
+			return false
+		}
+	}
+	return true
+}
+
+// addressesAvailableCondition returns the AddressesAvailable condition of a pool.
+func addressesAvailableCondition(available bool, message string) metav1.Condition {
+	if available {
+		return metav1.Condition{
+			Type:   v1alpha1.AddressesAvailableCondition,
+			Status: metav1.ConditionTrue,
+			Reason: v1alpha1.AddressesAvailableReason,
+		}
+	}
+	return metav1.Condition{
+		Type:    v1alpha1.AddressesAvailableCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  v1alpha1.SubnetExhaustedReason,
+		Message: message,
+	}
+}
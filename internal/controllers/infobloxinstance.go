@@ -18,7 +18,9 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
@@ -26,16 +28,21 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// DefaultHealthCheckInterval is the interval at which InfobloxInstances are checked if no other interval is configured.
+const DefaultHealthCheckInterval = 5 * time.Minute
+
 // InfobloxInstanceReconciler reconciles a InfobloxInstance object.
 type InfobloxInstanceReconciler struct {
 	Client client.Client
@@ -43,16 +50,31 @@ type InfobloxInstanceReconciler struct {
 
 	OperatorNamespace     string
 	NewInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
+	// HealthCheckInterval is the interval at which the connection to the Infoblox instances is checked.
+	// Defaults to DefaultHealthCheckInterval.
+	HealthCheckInterval time.Duration
+	Recorder            record.EventRecorder
+	// CredentialSources caches the credential sources of instances that don't use a Secret.
+	CredentialSources *CredentialSources
+	// RateLimiters holds the rate limiters of instances with a rate limit.
+	RateLimiters *RateLimiters
 }
 
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxinstances,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxinstances/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxinstances/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *InfobloxInstanceReconciler) SetupWithManager(_ context.Context, mgr ctrl.Manager) error {
+func (r *InfobloxInstanceReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.InfobloxInstance{}).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(
+			instancesForCABundle(r.Client, v1alpha1.CABundleKindConfigMap, r.OperatorNamespace),
+		), builder.WithPredicates(configMapReferencedByInstance(ctx, r.Client, r.OperatorNamespace))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+			instancesForSecret(r.Client, r.OperatorNamespace),
+		), builder.WithPredicates(secretReferencedByInstance(ctx, r.Client, r.OperatorNamespace))).
 		Complete(r)
 }
 
@@ -61,6 +83,8 @@ func (r *InfobloxInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	instance := &v1alpha1.InfobloxInstance{}
 	if err := r.Client.Get(ctx, req.NamespacedName, instance); err != nil {
 		if apierrors.IsNotFound(err) {
+			r.RateLimiters.forget(req.Name)
+			r.CredentialSources.forget(req.Name)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
@@ -76,58 +100,170 @@ func (r *InfobloxInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}()
 
-	return r.reconcile(ctx, instance)
+	readyBefore := readyCondition(instance)
+	res, err = r.reconcile(ctx, instance)
+	recordReadyChange(r.Recorder, instance, readyBefore)
+	if err != nil {
+		return res, err
+	}
+
+	// Instances are checked periodically, so their status reflects outages of the grid even if nothing else triggers a reconcile.
+	interval := r.HealthCheckInterval
+	if interval == 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	if res.RequeueAfter == 0 || res.RequeueAfter > interval {
+		res.RequeueAfter = interval
+	}
+	return res, nil
 }
 
-func (r *InfobloxInstanceReconciler) reconcile(ctx context.Context, instance *v1alpha1.InfobloxInstance) (ctrl.Result, error) { //nolint:unparam
+func (r *InfobloxInstanceReconciler) reconcile(ctx context.Context, instance *v1alpha1.InfobloxInstance) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	authSecret := &corev1.Secret{}
-	if err := r.Client.Get(ctx, types.NamespacedName{Name: instance.Spec.CredentialsSecretRef.Name, Namespace: r.OperatorNamespace}, authSecret); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return ctrl.Result{}, err
+	hostConfig := hostConfigForInstance(instance, r.RateLimiters)
+	// The health check starts with the primary endpoint, so the instance fails back once it is available again.
+	hostConfig.PreferredEndpoint = ""
+	result := ctrl.Result{}
+	if instance.Spec.CABundleRef.Name != "" {
+		caBundle, err := loadCABundle(ctx, r.Client, instance.Spec.CABundleRef, r.OperatorNamespace)
+		if err != nil {
+			if !apierrors.IsNotFound(err) && !errors.Is(err, errCABundleKeyMissing) {
+				return ctrl.Result{}, err
+			}
+			conditions.Set(instance, metav1.Condition{
+				Type:    v1alpha1.CABundleValidCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.CABundleInvalidReason,
+				Message: err.Error(),
+			})
+			conditions.Set(instance, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.CABundleInvalidReason,
+				Message: fmt.Sprintf("could not load CA bundle: %v", err),
+			})
+			return ctrl.Result{}, nil
+		}
+
+		condition, recheckAfter := caBundleCondition(caBundle, time.Now())
+		conditions.Set(instance, condition)
+		result.RequeueAfter = recheckAfter
+		hostConfig.CustomCA = caBundle
+	} else {
+		conditions.Delete(instance, v1alpha1.CABundleValidCondition)
+	}
+
+	var authConfig infoblox.AuthConfig
+	if source := instance.Spec.CredentialsSource; source.UsesSecret() {
+		r.CredentialSources.forget(instance.Name)
+		secretKey := credentialsSecretKey(instance.Spec.CredentialsSecretRef, r.OperatorNamespace)
+		authSecret := &corev1.Secret{}
+		if err := r.Client.Get(ctx, secretKey, authSecret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+
+			conditions.Set(instance, metav1.Condition{
+				Type:   clusterv1.ReadyCondition,
+				Status: metav1.ConditionFalse,
+				Reason: v1alpha1.AuthenticationFailedReason,
+				Message: fmt.Sprintf("the referenced settings secret %q could not be found in namespace %q",
+					secretKey.Name, secretKey.Namespace),
+			})
+			return ctrl.Result{}, nil
 		}
 
+		var err error
+		authConfig, err = infoblox.AuthConfigFromSecretData(authSecret.Data)
+		if err != nil {
+			conditions.Set(instance, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.AuthenticationFailedReason,
+				Message: fmt.Sprintf("the referenced settings secret is invalid: %v", err),
+			})
+			return ctrl.Result{}, nil
+		}
+	} else {
+		authConfig = infoblox.AuthConfig{Source: r.CredentialSources.get(instance)}
+		if _, err := authConfig.Source.Credentials(); err != nil {
+			conditions.Set(instance, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.AuthenticationFailedReason,
+				Message: fmt.Sprintf("could not load credentials from the %s credentials source: %v", source.Type, err),
+			})
+			return ctrl.Result{}, nil
+		}
+	}
+
+	ibcl, err := r.NewInfobloxClientFunc(infoblox.Config{HostConfig: hostConfig, AuthConfig: authConfig})
+	if err != nil {
 		conditions.Set(instance, metav1.Condition{
-			Type:   clusterv1.ReadyCondition,
-			Status: metav1.ConditionFalse,
-			Reason: v1alpha1.AuthenticationFailedReason,
-			Message: fmt.Sprintf("the referenced settings secret %q could not be found in namespace %q",
-				instance.Spec.CredentialsSecretRef.Name, r.OperatorNamespace),
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.AuthenticationFailedReason,
+			Message: fmt.Sprintf("could not create infoblox client: %v", err),
 		})
 		return ctrl.Result{}, nil
 	}
 
-	authConfig, err := infoblox.AuthConfigFromSecretData(authSecret.Data)
+	schema, err := ibcl.GetSchema(infoblox.MinimumWAPIVersion)
+	if err == nil {
+		var version infoblox.WAPIVersion
+		version, err = schema.NegotiateVersion(instance.Spec.WAPIVersion)
+		if err != nil {
+			conditions.Set(instance, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.WAPIVersionUnsupportedReason,
+				Message: err.Error(),
+			})
+			return result, nil
+		}
+		// The supported objects depend on the requested version.
+		if version != infoblox.MinimumWAPIVersion {
+			schema, err = ibcl.GetSchema(version)
+		}
+		hostConfig.Version = version.String()
+	}
 	if err != nil {
+		logger.Error(err, "failed to query WAPI schema")
 		conditions.Set(instance, metav1.Condition{
 			Type:    clusterv1.ReadyCondition,
 			Status:  metav1.ConditionFalse,
-			Reason:  v1alpha1.AuthenticationFailedReason,
-			Message: fmt.Sprintf("the referenced settings secret is invalid: %v", err),
+			Reason:  infobloxErrorReason(err, v1alpha1.InstanceUnreachableReason),
+			Message: err.Error(),
 		})
-		return ctrl.Result{}, nil
+		return result, nil
 	}
+	hostConfig.Features = schema.Features()
+	instance.Status.WAPIVersion = hostConfig.Version
+	instance.Status.Features = hostConfig.Features
 
-	hc := infoblox.HostConfig{
-		Host:                   instance.Spec.Host,
-		Version:                instance.Spec.WAPIVersion,
-		DisableTLSVerification: instance.Spec.DisableTLSVerification,
-		CustomCAPath:           instance.Spec.CustomCAPath,
-		DefaultNetworkView:     instance.Spec.DefaultNetworkView,
-		DefaultDNSView:         instance.Spec.DefaultDNSView,
+	// Recreate the client with the negotiated version and features. This doesn't fail, since the same configuration was used before.
+	ibcl, err = r.NewInfobloxClientFunc(infoblox.Config{HostConfig: hostConfig, AuthConfig: authConfig})
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	ibcl, err := r.NewInfobloxClientFunc(infoblox.Config{HostConfig: hc, AuthConfig: authConfig})
+	info, err := ibcl.GetGridInfo()
 	if err != nil {
+		logger.Error(err, "health check failed")
 		conditions.Set(instance, metav1.Condition{
 			Type:    clusterv1.ReadyCondition,
 			Status:  metav1.ConditionFalse,
-			Reason:  v1alpha1.AuthenticationFailedReason,
-			Message: fmt.Sprintf("could not create infoblox client: %v", err),
+			Reason:  infobloxErrorReason(err, v1alpha1.InstanceUnreachableReason),
+			Message: fmt.Sprintf("health check failed: %v", err),
 		})
-		return ctrl.Result{}, nil
+		return result, nil
 	}
+	instance.Status.LastContactTime = metav1.Now()
+	instance.Status.Latency = metav1.Duration{Duration: info.Latency}
+	instance.Status.GridName = info.Name
+	instance.Status.NIOSVersion = info.NIOSVersion
+	instance.Status.ActiveEndpoint = info.Endpoint
 
 	// Check default network view if specified
 	if instance.Spec.DefaultNetworkView != "" {
@@ -164,5 +300,5 @@ func (r *InfobloxInstanceReconciler) reconcile(ctx context.Context, instance *v1
 		Reason:  v1alpha1.ConfigurationValidReason,
 		Message: "Successfully connected to Infoblox instance and validated configuration",
 	})
-	return ctrl.Result{}, nil
+	return result, nil
 }
@@ -1,16 +1,24 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	. "sigs.k8s.io/controller-runtime/pkg/envtest/komega"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("InfobloxInstance controller", func() {
@@ -95,11 +103,92 @@ var _ = Describe("InfobloxInstance controller", func() {
 		})
 	})
 
+	When("the referenced CA bundle is not found", func() {
+		BeforeEach(func() {
+			instance.Spec.CABundleRef = v1alpha1.CABundleReference{
+				Kind:      v1alpha1.CABundleKindConfigMap,
+				Name:      "infoblox-ca",
+				Namespace: "default",
+			}
+			createObj(instance)
+		})
+
+		AfterEach(func() {
+			deleteObj(&v1alpha1.InfobloxInstance{}, instance.Name, instance.Namespace)
+		})
+
+		It("should set the InfobloxInstance to not ready", func() {
+			Eventually(Object(&v1alpha1.InfobloxInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      instance.Name,
+					Namespace: instance.Namespace,
+				},
+			})).WithTimeout(time.Second).WithPolling(100 * time.Millisecond).Should(And(
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", BeEquivalentTo(v1alpha1.CABundleValidCondition)),
+					HaveField("Status", BeEquivalentTo(metav1.ConditionFalse)),
+					HaveField("Reason", BeEquivalentTo(v1alpha1.CABundleInvalidReason)),
+				))),
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", BeEquivalentTo(clusterv1.ReadyCondition)),
+					HaveField("Status", BeEquivalentTo(metav1.ConditionFalse)),
+					HaveField("Reason", BeEquivalentTo(v1alpha1.CABundleInvalidReason)),
+				)))))
+		})
+	})
+
+	When("the instance is reachable", func() {
+		var secret *corev1.Secret
+
+		BeforeEach(func() {
+			instance.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{
+				Name:      "reachable",
+				Namespace: "default",
+			}
+			instance.Spec.WAPIVersion = ""
+			createObj(instance)
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "reachable",
+					Namespace: "default",
+				},
+				StringData: map[string]string{
+					"username": "user",
+					"password": "pass",
+				},
+			}
+			createObj(secret)
+		})
+		AfterEach(func() {
+			deleteObj(&v1alpha1.InfobloxInstance{}, instance.Name, instance.Namespace)
+			deleteObj(&corev1.Secret{}, secret.Name, secret.Namespace)
+		})
+
+		It("should record the health check in the status", func() {
+			Eventually(Object(&v1alpha1.InfobloxInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      instance.Name,
+					Namespace: instance.Namespace,
+				},
+			})).WithTimeout(time.Second).WithPolling(100 * time.Millisecond).Should(And(
+				HaveField("Status.GridName", Equal("Infoblox")),
+				HaveField("Status.WAPIVersion", Equal("2.12")),
+				HaveField("Status.ActiveEndpoint", Equal("localhost:443")),
+				HaveField("Status.Features", ConsistOf(infoblox.FeatureExtensibleAttributes, infoblox.FeatureIPv6, infoblox.FeatureIPv6NetworkContainers)),
+				HaveField("Status.LastContactTime.Time", Not(BeZero())),
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", BeEquivalentTo(clusterv1.ReadyCondition)),
+					HaveField("Status", BeEquivalentTo(metav1.ConditionTrue)),
+				)))))
+		})
+	})
+
 	When("the provided credentials are invalid", func() {
 		var secret *corev1.Secret
 		BeforeEach(func() {
 			instance.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{
-				Name: "test",
+				Name:      "test",
+				Namespace: "default",
 			}
 			createObj(instance)
 			secret = &corev1.Secret{
@@ -108,7 +197,7 @@ var _ = Describe("InfobloxInstance controller", func() {
 					Namespace: "default",
 				},
 				StringData: map[string]string{
-					"username": "user",
+					"username": "invalid",
 					"password": "pass",
 				},
 			}
@@ -120,12 +209,124 @@ var _ = Describe("InfobloxInstance controller", func() {
 		})
 
 		It("should set the InfobloxInstance to not ready", func() {
-
+			Eventually(Object(&v1alpha1.InfobloxInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      instance.Name,
+					Namespace: instance.Namespace,
+				},
+			})).WithTimeout(time.Second).WithPolling(100 * time.Millisecond).Should(
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", BeEquivalentTo(clusterv1.ReadyCondition)),
+					HaveField("Status", BeEquivalentTo(metav1.ConditionFalse)),
+					HaveField("Reason", BeEquivalentTo(v1alpha1.AuthenticationFailedReason)),
+				))))
 		})
 	})
 
 })
 
+var _ = Describe("CredentialSources", func() {
+	tokenExchangeInstance := func(url string) *v1alpha1.InfobloxInstance {
+		return &v1alpha1.InfobloxInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-exchange"},
+			Spec: v1alpha1.InfobloxInstanceSpec{
+				CredentialsSource: v1alpha1.CredentialsSource{
+					Type:          v1alpha1.CredentialsSourceTypeTokenExchange,
+					TokenExchange: v1alpha1.TokenExchangeCredentialsSource{URL: url},
+				},
+			},
+		}
+	}
+
+	It("should reuse the source of an instance until its credentials source changes", func() {
+		sources := NewCredentialSources()
+		first := sources.get(tokenExchangeInstance("http://127.0.0.1:8100/v1/secret/data/infoblox"))
+		Expect(sources.get(tokenExchangeInstance("http://127.0.0.1:8100/v1/secret/data/infoblox"))).To(BeIdenticalTo(first))
+
+		changed := sources.get(tokenExchangeInstance("http://127.0.0.1:8100/v1/secret/data/other"))
+		Expect(changed).NotTo(BeIdenticalTo(first))
+		Expect(sources.sources).To(HaveLen(1))
+	})
+
+	It("should drop the source of a forgotten instance", func() {
+		sources := NewCredentialSources()
+		sources.get(tokenExchangeInstance("http://127.0.0.1:8100/v1/secret/data/infoblox"))
+		sources.forget("token-exchange")
+		Expect(sources.sources).To(BeEmpty())
+	})
+
+	It("should only match secrets referenced by an instance", func() {
+		instance := &v1alpha1.InfobloxInstance{
+			Spec: v1alpha1.InfobloxInstanceSpec{
+				CredentialsSecretRef: v1alpha1.CredentialsReferece{Name: "creds"},
+				CABundleRef:          v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindSecret, Name: "ca", Namespace: "certs"},
+			},
+		}
+		Expect(instanceReferencesSecret(instance, types.NamespacedName{Name: "creds", Namespace: "operator"}, "operator")).To(BeTrue())
+		Expect(instanceReferencesSecret(instance, types.NamespacedName{Name: "ca", Namespace: "certs"}, "operator")).To(BeTrue())
+		Expect(instanceReferencesSecret(instance, types.NamespacedName{Name: "creds", Namespace: "default"}, "operator")).To(BeFalse())
+		Expect(instanceReferencesSecret(instance, types.NamespacedName{Name: "unrelated", Namespace: "operator"}, "operator")).To(BeFalse())
+
+		instance.Spec.CredentialsSource = v1alpha1.CredentialsSource{Type: v1alpha1.CredentialsSourceTypeFile}
+		Expect(instanceReferencesSecret(instance, types.NamespacedName{Name: "creds", Namespace: "operator"}, "operator")).To(BeFalse())
+	})
+
+	It("should only accept config maps referenced by an instance as CA bundle", func() {
+		instance := &v1alpha1.InfobloxInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "with-ca-bundle"},
+			Spec: v1alpha1.InfobloxInstanceSpec{
+				CABundleRef: v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindConfigMap, Name: "ca"},
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(instance).Build()
+		p := configMapReferencedByInstance(context.Background(), c, "operator")
+
+		Expect(p.Generic(event.GenericEvent{Object: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "operator"}}})).To(BeTrue())
+		Expect(p.Generic(event.GenericEvent{Object: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "default"}}})).To(BeFalse())
+		Expect(p.Generic(event.GenericEvent{Object: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "operator"}}})).To(BeFalse())
+	})
+
+	It("should not accept secrets if the instances can't be listed", func() {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			List: func(context.Context, client.WithWatch, client.ObjectList, ...client.ListOption) error {
+				return errors.New("cache not synced")
+			},
+		}).Build()
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "operator"}}
+		Expect(secretReferencedByInstance(context.Background(), c, "operator").Generic(event.GenericEvent{Object: secret})).To(BeFalse())
+	})
+})
+
+var _ = Describe("RateLimiters", func() {
+	limitedInstance := func(qps int32) *v1alpha1.InfobloxInstance {
+		return &v1alpha1.InfobloxInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "limited"},
+			Spec:       v1alpha1.InfobloxInstanceSpec{RateLimit: v1alpha1.RateLimitConfig{QPS: qps}},
+		}
+	}
+
+	It("should share the limiter of an instance and update its limit", func() {
+		limiters := NewRateLimiters()
+		first := limiters.get(limitedInstance(10))
+		Expect(limiters.get(limitedInstance(10))).To(BeIdenticalTo(first))
+
+		Expect(limiters.get(limitedInstance(20))).To(BeIdenticalTo(first))
+		Expect(first.Limit()).To(BeNumerically("==", 20))
+		Expect(first.Burst()).To(Equal(20))
+	})
+
+	It("should drop the limiter of a forgotten or unlimited instance", func() {
+		limiters := NewRateLimiters()
+		limiters.get(limitedInstance(10))
+		limiters.forget("limited")
+		Expect(limiters.limiters).To(BeEmpty())
+
+		limiters.get(limitedInstance(10))
+		Expect(limiters.get(limitedInstance(0))).To(BeNil())
+		Expect(limiters.limiters).To(BeEmpty())
+	})
+})
+
 func createObj(object client.Object) {
 	Expect(k8sClient.Create(ctx, object)).To(Succeed())
 	Eventually(Get(object)).Should(Succeed())
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net/netip"
+	"time"
 
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/poolutil"
@@ -28,13 +29,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -50,24 +54,37 @@ type InfobloxIPPoolReconciler struct {
 
 	OperatorNamespace     string
 	NewInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
+	Recorder              record.EventRecorder
+	// AuditLog receives a record for every change made in Infoblox, if set.
+	AuditLog *infoblox.AuditLog
+	// CredentialSources caches the credential sources of instances that don't use a Secret.
+	CredentialSources *CredentialSources
+	// RateLimiters holds the rate limiters of instances with a rate limit.
+	RateLimiters *RateLimiters
 }
 
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxippools,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxippools/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=infobloxippools/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *InfobloxIPPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		// Uncomment the following line adding a pointer to an instance of the controlled resource as an argument
 		For(&v1alpha1.InfobloxIPPool{}).
+		Watches(&ipamv1.IPAddressClaim{}, handler.EnqueueRequestsFromMapFunc(
+			claimToPoolWithQuota(r.Client, "InfobloxIPPool", func() v1alpha1.GenericInfobloxPool { return &v1alpha1.InfobloxIPPool{} }),
+		)).
+		Watches(&v1alpha1.InfobloxInstance{}, handler.EnqueueRequestsFromMapFunc(
+			instanceToPools(r.Client, func() client.ObjectList { return &v1alpha1.InfobloxIPPoolList{} }),
+		)).
 		Complete(r)
 }
 
 // Reconcile an InfobloxIPPool.
-func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
-	logger := log.FromContext(ctx)
-
+func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	// get object
 	pool := &v1alpha1.InfobloxIPPool{}
 	if err := r.Client.Get(ctx, req.NamespacedName, pool); err != nil {
@@ -77,8 +94,108 @@ func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, err
 	}
 
+	return (&genericPoolReconciler{
+		client:                r.Client,
+		operatorNamespace:     r.OperatorNamespace,
+		newInfobloxClientFunc: r.NewInfobloxClientFunc,
+		recorder:              r.Recorder,
+		auditLog:              r.AuditLog,
+		credentialSources:     r.CredentialSources,
+		rateLimiters:          r.RateLimiters,
+	}).reconcile(ctx, pool)
+}
+
+// GlobalInfobloxIPPoolReconciler reconciles a GlobalInfobloxIPPool object.
+type GlobalInfobloxIPPoolReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+
+	OperatorNamespace     string
+	NewInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
+	Recorder              record.EventRecorder
+	// AuditLog receives a record for every change made in Infoblox, if set.
+	AuditLog *infoblox.AuditLog
+	// CredentialSources caches the credential sources of instances that don't use a Secret.
+	CredentialSources *CredentialSources
+	// RateLimiters holds the rate limiters of instances with a rate limit.
+	RateLimiters *RateLimiters
+}
+
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=globalinfobloxippools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=globalinfobloxippools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=globalinfobloxippools/finalizers,verbs=update
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GlobalInfobloxIPPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.GlobalInfobloxIPPool{}).
+		Watches(&ipamv1.IPAddressClaim{}, handler.EnqueueRequestsFromMapFunc(
+			claimToPoolWithQuota(r.Client, "GlobalInfobloxIPPool", func() v1alpha1.GenericInfobloxPool { return &v1alpha1.GlobalInfobloxIPPool{} }),
+		)).
+		Watches(&v1alpha1.InfobloxInstance{}, handler.EnqueueRequestsFromMapFunc(
+			instanceToPools(r.Client, func() client.ObjectList { return &v1alpha1.GlobalInfobloxIPPoolList{} }),
+		)).
+		Complete(r)
+}
+
+// Reconcile a GlobalInfobloxIPPool.
+func (r *GlobalInfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// get object
+	pool := &v1alpha1.GlobalInfobloxIPPool{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	return (&genericPoolReconciler{
+		client:                r.Client,
+		operatorNamespace:     r.OperatorNamespace,
+		newInfobloxClientFunc: r.NewInfobloxClientFunc,
+		recorder:              r.Recorder,
+		auditLog:              r.AuditLog,
+		credentialSources:     r.CredentialSources,
+		rateLimiters:          r.RateLimiters,
+	}).reconcile(ctx, pool)
+}
+
+// genericPoolReconciler contains the reconciliation logic shared by InfobloxIPPool and GlobalInfobloxIPPool.
+type genericPoolReconciler struct {
+	client                client.Client
+	operatorNamespace     string
+	newInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
+	recorder              record.EventRecorder
+	auditLog              *infoblox.AuditLog
+	credentialSources     *CredentialSources
+	rateLimiters          *RateLimiters
+}
+
+func (r *genericPoolReconciler) reconcile(ctx context.Context, pool v1alpha1.GenericInfobloxPool) (res ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	// clusterctl move deletes the pool from the source cluster once it has been created in the target cluster.
+	if isDeletedForMove(pool) {
+		return r.reconcileDeleteForMove(ctx, pool)
+	}
+
+	// Paused pools, e.g. while they are moved to another cluster, are not reconciled and nothing is released in Infoblox.
+	if annotations.HasPaused(pool) {
+		logger.Info("pool is paused, skipping reconciliation")
+		return ctrl.Result{}, nil
+	}
+
+	// Retained addresses are restored and released before the patch helper is created, since they are patched with an optimistic lock.
+	if err := restoreRetainedAddresses(ctx, r.client, pool); err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter, err := r.releaseRetainedAddresses(ctx, pool, time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// setup patch helper
-	patchHelper, err := patch.NewHelper(pool, r.Client)
+	patchHelper, err := patch.NewHelper(pool, r.client)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -89,6 +206,10 @@ func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		}
 	}()
 
+	// Changes of the Ready condition, e.g. missing views or networks, are recorded as Events.
+	readyBefore := readyCondition(pool)
+	defer recordReadyChange(r.recorder, pool, readyBefore)
+
 	// add finalizer
 	isMarkedForDeletion := pool.GetDeletionTimestamp() != nil
 	if !isMarkedForDeletion && controllerutil.AddFinalizer(pool, ProtectPoolFinalizer) {
@@ -102,7 +223,8 @@ func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			Kind:     pool.GetObjectKind().GroupVersionKind().Kind,
 			Name:     pool.GetName(),
 		}
-		inUseClaims, err := poolutil.ListClaimsReferencingPool(ctx, r.Client, pool.GetNamespace(), poolTypeRef)
+		// cluster-scoped pools have an empty namespace, so claims from all namespaces are listed
+		inUseClaims, err := poolutil.ListClaimsReferencingPool(ctx, r.client, pool.GetNamespace(), poolTypeRef)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -110,6 +232,12 @@ func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			logger.Info("still found claim in use", "claim", claim.Name)
 		}
 		if len(inUseClaims) == 0 {
+			if _, err := r.releaseQuarantinedAddresses(ctx, pool, time.Now()); err != nil {
+				return ctrl.Result{}, err
+			}
+			if err := r.releaseNetwork(ctx, pool); err != nil {
+				return ctrl.Result{}, err
+			}
 			if controllerutil.RemoveFinalizer(pool, ProtectPoolFinalizer) {
 				return ctrl.Result{}, nil
 			}
@@ -118,44 +246,135 @@ func (r *InfobloxIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, fmt.Errorf("pool has IPAddresses or IPAddressClaims allocated. Cannot delete Pool until all IPAddresses and IPAddressClaims have been removed")
 	}
 
-	return ctrl.Result{}, r.reconcile(ctx, pool)
+	if err := r.updateQuotaUsage(ctx, pool); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileNormal(ctx, pool); err != nil || !conditions.IsTrue(pool, clusterv1.ReadyCondition) {
+		return ctrl.Result{RequeueAfter: requeueAfter}, err
+	}
+
+	quarantineRequeueAfter, err := r.releaseQuarantinedAddresses(ctx, pool, time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if requeueAfter == 0 || (quarantineRequeueAfter > 0 && quarantineRequeueAfter < requeueAfter) {
+		requeueAfter = quarantineRequeueAfter
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// newClientFunc returns a function that creates Infoblox clients which record Events and audit records for the changes made for the pool.
+func (r *genericPoolReconciler) newClientFunc(pool v1alpha1.GenericInfobloxPool) func(infoblox.Config) (infoblox.Client, error) {
+	newClientFn := withChangeEvents(r.newInfobloxClientFunc, r.recorder, pool)
+	return withAudit(newClientFn, infoblox.AuditContext{Log: r.auditLog, Pool: networkOwner(pool)})
 }
 
-func (r *InfobloxIPPoolReconciler) reconcile(ctx context.Context, pool *v1alpha1.InfobloxIPPool) error {
+// updateQuotaUsage records the addresses held per namespace and Cluster in the pool status.
+func (r *genericPoolReconciler) updateQuotaUsage(ctx context.Context, pool v1alpha1.GenericInfobloxPool) error {
+	quota := pool.PoolSpec().Quota
+	if quota == (v1alpha1.Quota{}) {
+		pool.PoolStatus().QuotaUsage = nil
+		return nil
+	}
+
+	claims, err := listClaimsHoldingAddresses(ctx, r.client, pool)
+	if err != nil {
+		return err
+	}
+	pool.PoolStatus().QuotaUsage = quotaUsage(countAddresses(claims), quota)
+	return nil
+}
+
+func (r *genericPoolReconciler) reconcileNormal(ctx context.Context, pool v1alpha1.GenericInfobloxPool) error {
 	logger := log.FromContext(ctx)
+	spec := pool.PoolSpec()
+
+	unreachable, err := instanceUnreachableMessage(ctx, r.client, spec.InstanceRef.Name)
+	if err != nil {
+		return err
+	}
+	if unreachable != "" {
+		conditions.Set(pool, metav1.Condition{
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.InstanceUnreachableReason,
+			Message: fmt.Sprintf("instance %q is unreachable: %s", spec.InstanceRef.Name, unreachable),
+		})
+		return nil
+	}
 
-	ibclient, err := getInfobloxClientForInstance(ctx, r.Client, pool.Spec.InstanceRef.Name, r.OperatorNamespace, r.NewInfobloxClientFunc)
+	ibclient, err := getInfobloxClientForInstance(ctx, r.client, spec.InstanceRef.Name, r.operatorNamespace, r.credentialSources, r.rateLimiters, r.newClientFunc(pool))
 	if err != nil {
 		conditions.Set(pool, metav1.Condition{
 			Type:    clusterv1.ReadyCondition,
 			Status:  metav1.ConditionFalse,
 			Reason:  v1alpha1.AuthenticationFailedReason,
-			Message: fmt.Sprintf("client creation failed for instance %q: %s", pool.Spec.InstanceRef.Name, err),
+			Message: fmt.Sprintf("client creation failed for instance %q: %s", spec.InstanceRef.Name, err),
 		})
 		return err
 	}
 
-	if pool.Spec.NetworkView == "" {
-		pool.Spec.NetworkView = ibclient.GetHostConfig().DefaultNetworkView
+	// Namespaced pools are checked here, claims of global pools are checked when allocating.
+	if pool.GetNamespace() != "" {
+		allowed, err := instanceAllowsNamespace(ctx, r.client, spec.InstanceRef.Name, pool.GetNamespace())
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			conditions.Set(pool, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.InstanceNotPermittedReason,
+				Message: fmt.Sprintf("namespace %q is not allowed to use instance %q", pool.GetNamespace(), spec.InstanceRef.Name),
+			})
+			return nil
+		}
+	}
+
+	if spec.NetworkView == "" {
+		spec.NetworkView = ibclient.GetHostConfig().DefaultNetworkView
 	}
 
 	// TODO: handle this in a better way
-	if ok, err := ibclient.CheckNetworkViewExists(pool.Spec.NetworkView); err != nil || !ok {
-		logger.Error(err, "could not find network view", "networkView", pool.Spec.NetworkView)
+	ok, err := ibclient.CheckNetworkViewExists(spec.NetworkView)
+	if err != nil {
+		logger.Error(err, "could not check network view", "networkView", spec.NetworkView)
+		conditions.Set(pool, metav1.Condition{
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infobloxErrorReason(err, v1alpha1.NetworkViewNotFoundReason),
+			Message: fmt.Sprintf("could not check network view %q: %v", spec.NetworkView, err),
+		})
+		return requeueInfobloxError(err)
+	}
+	if !ok {
+		logger.Info("could not find network view", "networkView", spec.NetworkView)
 		conditions.Set(pool, metav1.Condition{
 			Type:    clusterv1.ReadyCondition,
 			Status:  metav1.ConditionFalse,
 			Reason:  v1alpha1.NetworkViewNotFoundReason,
-			Message: fmt.Sprintf("could not find network view %q", pool.Spec.NetworkView),
+			Message: fmt.Sprintf("could not find network view %q", spec.NetworkView),
 		})
 		return nil
 	}
 
 	// Check DNS view if specified
-	dnsView := determineDNSView(pool.Spec.DNSView, ibclient.GetHostConfig().DefaultDNSView, pool.Spec.NetworkView)
+	dnsView := determineDNSView(spec.DNSView, ibclient.GetHostConfig().DefaultDNSView, spec.NetworkView)
 	if dnsView != "" {
-		if ok, err := ibclient.CheckDNSViewExists(dnsView); err != nil || !ok {
-			logger.Error(err, "could not find DNS view", "dnsView", dnsView)
+		ok, err := ibclient.CheckDNSViewExists(dnsView)
+		if err != nil {
+			logger.Error(err, "could not check DNS view", "dnsView", dnsView)
+			conditions.Set(pool, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  infobloxErrorReason(err, v1alpha1.DNSViewNotFoundReason),
+				Message: fmt.Sprintf("could not check DNS view %q: %v", dnsView, err),
+			})
+			return requeueInfobloxError(err)
+		}
+		if !ok {
+			logger.Info("could not find DNS view", "dnsView", dnsView)
 			conditions.Set(pool, metav1.Condition{
 				Type:    clusterv1.ReadyCondition,
 				Status:  metav1.ConditionFalse,
@@ -166,19 +385,42 @@ func (r *InfobloxIPPoolReconciler) reconcile(ctx context.Context, pool *v1alpha1
 		}
 	}
 
-	for _, sub := range pool.Spec.Subnets {
+	if spec.NetworkContainer.CIDR != "" {
+		if err := r.ensureNetwork(ctx, ibclient, pool); err != nil {
+			conditions.Set(pool, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  infobloxErrorReason(err, v1alpha1.NetworkAllocationFailedReason),
+				Message: err.Error(),
+			})
+			return err
+		}
+	}
+
+	for _, sub := range poolSubnets(pool) {
 		subnet, err := netip.ParsePrefix(sub.CIDR)
 		if err != nil {
 			// We won't set a condition here since this should be caught by validation
 			return fmt.Errorf("failed to parse subnet: %w", err)
 		}
-		if ok, err := ibclient.CheckNetworkExists(pool.Spec.NetworkView, subnet); err != nil || !ok {
-			logger.Error(err, "could not find network", "networkView", pool.Spec.NetworkView, "subnet", subnet)
+		ok, err := ibclient.CheckNetworkExists(spec.NetworkView, subnet)
+		if err != nil {
+			logger.Error(err, "could not check network", "networkView", spec.NetworkView, "subnet", subnet)
+			conditions.Set(pool, metav1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  infobloxErrorReason(err, v1alpha1.NetworkNotFoundReason),
+				Message: fmt.Sprintf("could not check network %q in view %q: %v", subnet, spec.NetworkView, err),
+			})
+			return requeueInfobloxError(err)
+		}
+		if !ok {
+			logger.Info("could not find network", "networkView", spec.NetworkView, "subnet", subnet)
 			conditions.Set(pool, metav1.Condition{
 				Type:    clusterv1.ReadyCondition,
 				Status:  metav1.ConditionFalse,
 				Reason:  v1alpha1.NetworkNotFoundReason,
-				Message: fmt.Sprintf("could not find network %q in view %q", subnet, pool.Spec.NetworkView),
+				Message: fmt.Sprintf("could not find network %q in view %q", subnet, spec.NetworkView),
 			})
 			return nil
 		}
@@ -193,6 +435,80 @@ func (r *InfobloxIPPoolReconciler) reconcile(ctx context.Context, pool *v1alpha1
 	return nil
 }
 
+// ensureNetwork allocates a network from the pool's network container and records it in the pool status.
+func (r *genericPoolReconciler) ensureNetwork(ctx context.Context, ibclient infoblox.Client, pool v1alpha1.GenericInfobloxPool) error {
+	logger := log.FromContext(ctx)
+	spec := pool.PoolSpec()
+
+	container, err := netip.ParsePrefix(spec.NetworkContainer.CIDR)
+	if err != nil {
+		// This should be caught by validation
+		return fmt.Errorf("failed to parse network container: %w", err)
+	}
+
+	subnet, err := ibclient.GetOrAllocateNetwork(spec.NetworkView, container, int(spec.NetworkContainer.PrefixLength), networkOwner(pool), logger)
+	if err != nil {
+		return err
+	}
+
+	pool.PoolStatus().NetworkContainerSubnet = v1alpha1.Subnet{CIDR: subnet.String()}
+	return nil
+}
+
+// releaseNetwork deletes the network that has been allocated from the pool's network container, if any.
+func (r *genericPoolReconciler) releaseNetwork(ctx context.Context, pool v1alpha1.GenericInfobloxPool) error {
+	logger := log.FromContext(ctx)
+	spec := pool.PoolSpec()
+	status := pool.PoolStatus()
+
+	if status.NetworkContainerSubnet.CIDR == "" {
+		return nil
+	}
+
+	subnet, err := netip.ParsePrefix(status.NetworkContainerSubnet.CIDR)
+	if err != nil {
+		return fmt.Errorf("failed to parse network container subnet: %w", err)
+	}
+
+	ibclient, err := getInfobloxClientForInstance(ctx, r.client, spec.InstanceRef.Name, r.operatorNamespace, r.credentialSources, r.rateLimiters, r.newClientFunc(pool))
+	if err != nil {
+		return fmt.Errorf("failed to get infoblox client: %w", err)
+	}
+
+	networkView := spec.NetworkView
+	if networkView == "" {
+		networkView = ibclient.GetHostConfig().DefaultNetworkView
+	}
+
+	if err := ibclient.ReleaseNetwork(networkView, subnet, networkOwner(pool), logger); err != nil {
+		return err
+	}
+
+	status.NetworkContainerSubnet = v1alpha1.Subnet{}
+	return nil
+}
+
+// networkOwner returns the value used to mark networks in Infoblox as owned by the pool.
+func networkOwner(pool v1alpha1.GenericInfobloxPool) string {
+	if pool.GetNamespace() == "" {
+		return pool.GetName()
+	}
+	return pool.GetNamespace() + "/" + pool.GetName()
+}
+
+// poolSubnets returns the subnets addresses can be allocated from.
+func poolSubnets(pool v1alpha1.GenericInfobloxPool) []v1alpha1.Subnet {
+	spec := pool.PoolSpec()
+	if spec.NetworkContainer.CIDR != "" {
+		status := pool.PoolStatus()
+		if status.NetworkContainerSubnet.CIDR == "" {
+			return nil
+		}
+		return []v1alpha1.Subnet{status.NetworkContainerSubnet}
+	}
+	return spec.Subnets
+}
+
 // determineDNSView determines the DNS view to use based on the priority order:
 // 1. Pool.spec.dnsView (if set)
 // 2. Instance.spec.defaultDnsView (if not set on pool but set on instance)
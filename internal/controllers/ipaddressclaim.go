@@ -17,30 +17,37 @@ limitations under the License.
 package controllers
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"net/netip"
 	"strings"
+	"time"
 
-	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/hostname"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/poolutil"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
 	ipampredicates "github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/predicates"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/cluster-api-ipam-provider-in-cluster/pkg/ipamutil"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 var (
@@ -49,10 +56,30 @@ var (
 	hostnameAnnotation               = "ipam.cluster.x-k8s.io/hostname"
 )
 
+// Annotations describing the Infoblox host record of an address. They are set on the IPAddress.
+// The host record reference and the address are set on the claim as well, so the address can be released without resolving the hostname.
+const (
+	hostRecordRefAnnotation = "ipam.cluster.x-k8s.io/infoblox-host-record-ref"
+	addressAnnotation       = "ipam.cluster.x-k8s.io/infoblox-address"
+	fqdnAnnotation          = "ipam.cluster.x-k8s.io/infoblox-fqdn"
+	dnsViewAnnotation       = "ipam.cluster.x-k8s.io/infoblox-dns-view"
+	networkViewAnnotation   = "ipam.cluster.x-k8s.io/infoblox-network-view"
+	subnetAnnotation        = "ipam.cluster.x-k8s.io/infoblox-subnet"
+)
+
 // InfobloxProviderAdapter reconciles a InfobloxIPPool object.
 type InfobloxProviderAdapter struct {
 	NewInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
 	OperatorNamespace     string
+	Recorder              record.EventRecorder
+	// AuditLog receives a record for every change made in Infoblox, if set.
+	AuditLog *infoblox.AuditLog
+	// CredentialSources caches the credential sources of instances that don't use a Secret.
+	CredentialSources *CredentialSources
+	// RateLimiters holds the rate limiters of instances with a rate limit.
+	RateLimiters *RateLimiters
+
+	exhausted exhaustedClaims
 }
 
 var _ ipamutil.ProviderAdapter = &InfobloxProviderAdapter{}
@@ -61,10 +88,15 @@ var _ ipamutil.ProviderAdapter = &InfobloxProviderAdapter{}
 type InfobloxClaimHandler struct {
 	Client                client.Client
 	claim                 *ipamv1.IPAddressClaim
-	pool                  *v1alpha1.InfobloxIPPool
+	pool                  v1alpha1.GenericInfobloxPool
 	newInfobloxClientFunc func(config infoblox.Config) (infoblox.Client, error)
 	operatorNamespace     string
 	ibclient              infoblox.Client
+	recorder              record.EventRecorder
+	auditLog              *infoblox.AuditLog
+	credentialSources     *CredentialSources
+	rateLimiters          *RateLimiters
+	exhausted             *exhaustedClaims
 }
 
 var _ ipamutil.ClaimHandler = &InfobloxClaimHandler{}
@@ -73,21 +105,42 @@ var _ ipamutil.ClaimHandler = &InfobloxClaimHandler{}
 func (r *InfobloxProviderAdapter) SetupWithManager(_ context.Context, b *ctrl.Builder) error {
 	b.
 		For(&ipamv1.IPAddressClaim{}, builder.WithPredicates(
-			ipampredicates.ClaimReferencesPoolKind(metav1.GroupKind{
-				Group: v1alpha1.GroupVersion.Group,
-				Kind:  "InfobloxIPPool",
-			}),
+			predicate.Or(
+				ipampredicates.ClaimReferencesPoolKind(metav1.GroupKind{
+					Group: v1alpha1.GroupVersion.Group,
+					Kind:  "InfobloxIPPool",
+				}),
+				ipampredicates.ClaimReferencesPoolKind(metav1.GroupKind{
+					Group: v1alpha1.GroupVersion.Group,
+					Kind:  "GlobalInfobloxIPPool",
+				}),
+			),
 		)).
 		WithOptions(controller.Options{
 			// To avoid race conditions when allocating IP Addresses, we explicitly set this to 1
 			MaxConcurrentReconciles: 1,
 		}).
 		Owns(&ipamv1.IPAddress{}, builder.WithPredicates(
-			ipampredicates.AddressReferencesPoolKind(metav1.GroupKind{
-				Group: v1alpha1.GroupVersion.Group,
-				Kind:  "InfobloxIPPool",
-			}),
-		))
+			predicate.Or(
+				ipampredicates.AddressReferencesPoolKind(metav1.GroupKind{
+					Group: v1alpha1.GroupVersion.Group,
+					Kind:  "InfobloxIPPool",
+				}),
+				ipampredicates.AddressReferencesPoolKind(metav1.GroupKind{
+					Group: v1alpha1.GroupVersion.Group,
+					Kind:  "GlobalInfobloxIPPool",
+				}),
+			),
+		)).
+		// Claims of exhausted pools are retried once the capacity of the pool changes.
+		Watches(&v1alpha1.InfobloxIPPool{},
+			handler.EnqueueRequestsFromMapFunc(r.exhausted.requestsForPool),
+			builder.WithPredicates(poolCapacityChanged),
+		).
+		Watches(&v1alpha1.GlobalInfobloxIPPool{},
+			handler.EnqueueRequestsFromMapFunc(r.exhausted.requestsForPool),
+			builder.WithPredicates(poolCapacityChanged),
+		)
 	return nil
 }
 
@@ -98,6 +151,11 @@ func (r *InfobloxProviderAdapter) ClaimHandlerFor(cl client.Client, claim *ipamv
 		claim:                 claim,
 		newInfobloxClientFunc: r.NewInfobloxClientFunc,
 		operatorNamespace:     r.OperatorNamespace,
+		recorder:              r.Recorder,
+		auditLog:              r.AuditLog,
+		credentialSources:     r.CredentialSources,
+		rateLimiters:          r.RateLimiters,
+		exhausted:             &r.exhausted,
 	}
 }
 
@@ -106,6 +164,7 @@ func (r *InfobloxProviderAdapter) ClaimHandlerFor(cl client.Client, claim *ipamv
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims/status;ipaddresses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims/status;ipaddresses/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // for resolving hostnames
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datas;metal3machines,verbs=get;list;watch
@@ -117,8 +176,14 @@ func (h *InfobloxClaimHandler) FetchPool(ctx context.Context) (client.Object, *c
 
 	var err error
 
-	h.pool = &v1alpha1.InfobloxIPPool{}
-	if err = h.Client.Get(ctx, types.NamespacedName{Namespace: h.claim.Namespace, Name: h.claim.Spec.PoolRef.Name}, h.pool); err != nil {
+	poolKey := types.NamespacedName{Namespace: h.claim.Namespace, Name: h.claim.Spec.PoolRef.Name}
+	if h.claim.Spec.PoolRef.Kind == "GlobalInfobloxIPPool" {
+		h.pool = &v1alpha1.GlobalInfobloxIPPool{}
+		poolKey.Namespace = ""
+	} else {
+		h.pool = &v1alpha1.InfobloxIPPool{}
+	}
+	if err = h.Client.Get(ctx, poolKey, h.pool); err != nil {
 		if apierrors.IsNotFound(err) {
 			err := errors.New("pool could not be found")
 			logger.Error(err, "the referenced pool in the claim could not be found")
@@ -127,6 +192,13 @@ func (h *InfobloxClaimHandler) FetchPool(ctx context.Context) (client.Object, *c
 		return nil, nil, fmt.Errorf("failed to fetch pool: %w", err)
 	}
 
+	// Claims of exhausted pools are retried less often, unless the pool changed.
+	if h.claim.DeletionTimestamp.IsZero() {
+		if retryAfter := h.exhausted.retryAfter(h.pool, client.ObjectKeyFromObject(h.claim), time.Now()); retryAfter > 0 {
+			return h.pool, &ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+	}
+
 	// TODO: ensure pool is ready
 	if conditions.IsFalse(h.pool, clusterv1.ReadyCondition) {
 		conditions.Set(h.claim, metav1.Condition{
@@ -138,7 +210,22 @@ func (h *InfobloxClaimHandler) FetchPool(ctx context.Context) (client.Object, *c
 		return h.pool, nil, fmt.Errorf("pool not ready")
 	}
 
-	h.ibclient, err = getInfobloxClientForInstanceFunc(ctx, h.Client, h.pool.Spec.InstanceRef.Name, h.operatorNamespace, h.newInfobloxClientFunc)
+	// Claims are released even if their namespace isn't allowed anymore, so their host records don't leak.
+	if h.claim.DeletionTimestamp.IsZero() {
+		if err := h.ensureNamespaceAllowed(ctx); err != nil {
+			return h.pool, nil, err
+		}
+	}
+
+	// Host records created, updated or deleted for the claim are recorded as Events on the claim.
+	newClientFn := withChangeEvents(h.newInfobloxClientFunc, h.recorder, h.claim)
+	newClientFn = withAudit(newClientFn, infoblox.AuditContext{
+		Log:     h.auditLog,
+		Claim:   client.ObjectKeyFromObject(h.claim).String(),
+		Cluster: cmp.Or(h.claim.Labels[clusterv1.ClusterNameLabel], h.claim.Spec.ClusterName),
+		Pool:    networkOwner(h.pool),
+	})
+	h.ibclient, err = getInfobloxClientForInstanceFunc(ctx, h.Client, h.pool.PoolSpec().InstanceRef.Name, h.operatorNamespace, h.credentialSources, h.rateLimiters, newClientFn)
 	if err != nil {
 		return h.pool, nil, fmt.Errorf("failed to get infoblox client: %w", err)
 	}
@@ -152,6 +239,10 @@ func (h *InfobloxClaimHandler) EnsureAddress(ctx context.Context, address *ipamv
 
 	logger := log.FromContext(ctx)
 
+	if err := h.ensureQuota(ctx); err != nil {
+		return nil, err
+	}
+
 	hostName, err := h.ensureHostname(ctx)
 	if err != nil {
 		return nil, err
@@ -159,8 +250,25 @@ func (h *InfobloxClaimHandler) EnsureAddress(ctx context.Context, address *ipamv
 
 	logger = logger.WithValues("hostname", hostName)
 
+	// The host record retained for the hostname is found by GetOrAllocateAddress, so the claim receives the same address.
+	subnets := poolSubnets(h.pool)
+	retained, isRetained := retainedAddressFor(h.pool, hostName)
+	if addr, err := netip.ParseAddr(retained.Address); isRetained && err == nil {
+		subnets = preferSubnet(subnets, addr)
+	}
+	// The host record stored on the claim is adopted, e.g. after the claim has been moved by clusterctl move.
+	// It is only looked up until the address has been allocated, to avoid a request for every reconcile.
+	var adopted infoblox.Allocation
+	var isAdopted bool
+	if address.Spec.Address == "" {
+		adopted, isAdopted = h.adoptedAddress(ctx, hostName)
+	}
+	if isAdopted {
+		subnets = preferSubnet(subnets, adopted.Address)
+	}
+
 	var errs []error
-	for _, sub := range h.pool.Spec.Subnets {
+	for _, sub := range subnets {
 		subnet, err := netip.ParsePrefix(sub.CIDR)
 		if err != nil {
 			// We won't set a condition here since this should be caught by validation
@@ -168,59 +276,295 @@ func (h *InfobloxClaimHandler) EnsureAddress(ctx context.Context, address *ipamv
 			continue
 		}
 
-		dnsView := determineDNSView(h.pool.Spec.DNSView, h.ibclient.GetHostConfig().DefaultDNSView, h.pool.Spec.NetworkView)
-		allocatedAddr, err := h.ibclient.GetOrAllocateAddress(h.pool.Spec.NetworkView, dnsView, subnet, hostName, h.pool.Spec.DNSZone, logger)
-		if err != nil {
-			errs = append(errs, err)
-			continue
+		allocation := adopted
+		if !isAdopted || !subnet.Contains(adopted.Address) {
+			dnsView := determineDNSView(h.pool.PoolSpec().DNSView, h.ibclient.GetHostConfig().DefaultDNSView, h.pool.PoolSpec().NetworkView)
+			allocation, err = h.ibclient.GetOrAllocateAddress(h.pool.PoolSpec().NetworkView, dnsView, subnet, hostName, h.pool.PoolSpec().DNSZone, logger)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
 		}
+		allocatedAddr := allocation.Address
 
+		if address.Spec.Address != allocatedAddr.String() {
+			h.recorder.Eventf(h.claim, corev1.EventTypeNormal, v1alpha1.AddressAllocatedReason,
+				"Allocated address %s for hostname %s in subnet %s", allocatedAddr, hostName, subnet)
+		}
 		address.Spec.Address = allocatedAddr.String()
 		address.Spec.Prefix = ptr.To(int32(subnet.Bits())) //nolint:gosec // subnet prefix bits are always 0-128
 		address.Spec.Gateway = sub.Gateway
+		setAllocationAnnotations(address, allocation, subnet)
+		if allocation.Ref != "" {
+			h.claim.Annotations[hostRecordRefAnnotation] = allocation.Ref
+			h.claim.Annotations[addressAnnotation] = allocatedAddr.String()
+		}
 
 		conditions.Set(h.claim, metav1.Condition{
-			Type:   clusterv1.ReadyCondition,
-			Status: metav1.ConditionTrue,
-			Reason: v1alpha1.AddressAllocatedReason,
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.AddressAllocatedReason,
+			Message: allocationMessage(allocation, subnet),
 		})
 
+		// A retained address that has not been reused, e.g. because its subnet has been removed from the pool, is released once it expires.
+		if isRetained && retained.Address == allocatedAddr.String() {
+			if err := patchRetainedAddresses(ctx, h.Client, h.pool, withoutRetainedAddress(hostName)); err != nil {
+				return nil, err
+			}
+			logger.Info("reused retained address", "address", allocatedAddr)
+		}
+
+		h.exhausted.remove(h.pool, client.ObjectKeyFromObject(h.claim))
+		if err := h.setPoolAddressesAvailable(ctx, true, ""); err != nil {
+			logger.Error(err, "failed to update pool conditions")
+		}
 		return nil, nil
 	}
 
+	if isSubnetExhausted(errs) {
+		return nil, h.handleSubnetExhausted(ctx, errors.Join(errs...))
+	}
+
 	switch {
 	case len(errs) > 0:
 		err = errors.Join(errs...)
 	default:
 		err = errors.New("no (valid) subnets in IPPool")
 	}
+	reason := infobloxErrorReason(err, v1alpha1.AllocationFailedReason)
 	conditions.Set(h.claim, metav1.Condition{
 		Type:    clusterv1.ReadyCondition,
 		Status:  metav1.ConditionFalse,
-		Reason:  v1alpha1.AllocationFailedReason,
+		Reason:  reason,
 		Message: err.Error(),
 	})
+	h.recorder.Eventf(h.claim, corev1.EventTypeWarning, reason, "Could not allocate an address for hostname %s, retrying: %v", hostName, err)
 	logger.Error(err, "unable to ensure address allocated")
 	return nil, err
 }
 
+// setAllocationAnnotations records the host record of an allocation on the address.
+// Annotations for empty values, e.g. the DNS view of records without DNS, are removed.
+func setAllocationAnnotations(address *ipamv1.IPAddress, allocation infoblox.Allocation, subnet netip.Prefix) {
+	if address.Annotations == nil {
+		address.Annotations = map[string]string{}
+	}
+	for key, value := range map[string]string{
+		hostRecordRefAnnotation: allocation.Ref,
+		fqdnAnnotation:          allocation.Name,
+		dnsViewAnnotation:       allocation.DNSView,
+		networkViewAnnotation:   allocation.NetworkView,
+		subnetAnnotation:        subnet.String(),
+	} {
+		if value == "" {
+			delete(address.Annotations, key)
+			continue
+		}
+		address.Annotations[key] = value
+	}
+}
+
+// allocationMessage returns the message of the Ready condition of a claim describing where its address was allocated.
+func allocationMessage(allocation infoblox.Allocation, subnet netip.Prefix) string {
+	message := fmt.Sprintf("allocated %s from subnet %s", allocation.Address, subnet)
+	if allocation.NetworkView != "" {
+		message += fmt.Sprintf(" in network view %q", allocation.NetworkView)
+	}
+	if allocation.Name != "" {
+		message += fmt.Sprintf(" for host record %q", allocation.Name)
+	}
+	if allocation.DNSView != "" {
+		message += fmt.Sprintf(" in DNS view %q", allocation.DNSView)
+	}
+	if allocation.Ref != "" {
+		message += fmt.Sprintf(" (%s)", allocation.Ref)
+	}
+	return message
+}
+
+// handleSubnetExhausted reports that no address could be allocated because all subnets of the pool are exhausted.
+// The returned error requeues the claim with the usual short backoff. Until SubnetExhaustedRequeueInterval has passed,
+// FetchPool skips those reconciles and requeues the claim for the rest of the interval, unless the capacity of the pool changes.
+func (h *InfobloxClaimHandler) handleSubnetExhausted(ctx context.Context, err error) error {
+	logger := log.FromContext(ctx)
+
+	message := fmt.Sprintf("all subnets of the pool are exhausted: %v", err)
+	conditions.Set(h.claim, metav1.Condition{
+		Type:    clusterv1.ReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  v1alpha1.SubnetExhaustedReason,
+		Message: message,
+	})
+	h.exhausted.add(h.pool, client.ObjectKeyFromObject(h.claim), time.Now())
+	h.recorder.Eventf(h.claim, corev1.EventTypeWarning, v1alpha1.SubnetExhaustedReason,
+		"Could not allocate an address: all subnets of the pool are exhausted, retrying in %s", SubnetExhaustedRequeueInterval)
+	h.recorder.Eventf(h.pool, corev1.EventTypeWarning, v1alpha1.SubnetExhaustedReason,
+		"Could not allocate an address for claim %s/%s: all subnets are exhausted", h.claim.Namespace, h.claim.Name)
+	if err := h.setPoolAddressesAvailable(ctx, false, "all subnets of the pool are exhausted"); err != nil {
+		logger.Error(err, "failed to update pool conditions")
+	}
+	logger.Info("all subnets of the pool are exhausted", "retryAfter", SubnetExhaustedRequeueInterval)
+	// An error is returned instead of a result, since the IPAddress would be created without an address otherwise.
+	return errors.New(message)
+}
+
+// setPoolAddressesAvailable updates the AddressesAvailable condition of the pool if it changed.
+func (h *InfobloxClaimHandler) setPoolAddressesAvailable(ctx context.Context, available bool, message string) error {
+	condition := addressesAvailableCondition(available, message)
+	if current := conditions.Get(h.pool, condition.Type); current != nil && current.Status == condition.Status && current.Message == condition.Message {
+		return nil
+	}
+	patchHelper, err := patch.NewHelper(h.pool, h.Client)
+	if err != nil {
+		return err
+	}
+	conditions.Set(h.pool, condition)
+	return patchHelper.Patch(ctx, h.pool)
+}
+
 // ReleaseAddress releases address.
 func (h *InfobloxClaimHandler) ReleaseAddress(ctx context.Context) (*ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
+	// The claim created in the target cluster by clusterctl move adopts the host record, so it is neither released nor retained.
+	if isDeletedForMove(h.claim) {
+		logger.Info("claim is deleted by clusterctl move, keeping its host record")
+		return nil, nil
+	}
+
+	retained, err := h.retainAddress(ctx)
+	if err != nil || retained {
+		return nil, err
+	}
+
+	// The host record reference stored when allocating is preferred, since the hostname might not be resolvable anymore
+	// once the machine is being deleted. Claims allocated by older versions are released by hostname.
+	released, err := h.releaseAddressByRef(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		released, err = h.releaseAddressByHostname(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The quarantine is also retried if the address has been released by a previous attempt, so the claim is only
+	// removed once the address is reserved.
+	if err := h.quarantineReleasedAddress(ctx); err != nil {
+		return nil, err
+	}
+
+	// The released address can be allocated for claims that are waiting for the exhausted pool.
+	if released && conditions.IsFalse(h.pool, v1alpha1.AddressesAvailableCondition) {
+		if err := h.setPoolAddressesAvailable(ctx, true, ""); err != nil {
+			logger.Error(err, "failed to update pool conditions")
+		}
+	}
+
+	return nil, nil
+}
+
+// retainAddress keeps the host record of the claim for the retention period of the pool instead of releasing it,
+// so a new claim for the same hostname receives the same address. It returns false if the pool doesn't retain addresses,
+// is being deleted, or the claim was allocated by an older version without a host record reference.
+func (h *InfobloxClaimHandler) retainAddress(ctx context.Context) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	period := h.pool.PoolSpec().Retention.Period.Duration
+	if period <= 0 || !h.pool.GetDeletionTimestamp().IsZero() {
+		return false, nil
+	}
+	ref := h.claim.Annotations[hostRecordRefAnnotation]
+	hostName := h.claim.Annotations[hostnameAnnotation]
+	addr, err := netip.ParseAddr(h.claim.Annotations[addressAnnotation])
+	if ref == "" || hostName == "" || err != nil {
+		return false, nil
+	}
+	subnet, ok := subnetContaining(h.pool, addr)
+	if !ok {
+		return false, nil
+	}
+
+	expiration := time.Now().Add(period)
+	entry := retainedAddress(hostName, addr, subnet, ref, expiration)
+	err = patchRetainedAddresses(ctx, h.Client, h.pool, func(retained []v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+		return append(withoutRetainedAddress(hostName)(retained), entry)
+	})
+	if err != nil {
+		return false, err
+	}
+	logger.Info("retained address", "hostname", hostName, "address", addr, "hostRecord", ref, "expirationTime", expiration)
+	h.recorder.Eventf(h.claim, corev1.EventTypeNormal, AddressRetainedEventReason,
+		"Retained address %s of hostname %s until %s", addr, hostName, expiration.UTC().Format(time.RFC3339))
+	return true, nil
+}
+
+// releaseAddressByRef releases the address stored on the claim from the host record stored on the claim.
+// It returns false if the claim doesn't reference a host record or the host record doesn't exist anymore.
+func (h *InfobloxClaimHandler) releaseAddressByRef(ctx context.Context) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	ref := h.claim.Annotations[hostRecordRefAnnotation]
+	addr, err := netip.ParseAddr(h.claim.Annotations[addressAnnotation])
+	if ref == "" || err != nil {
+		return false, nil
+	}
+
+	logger = logger.WithValues("hostRecord", ref, "address", addr)
+	err = h.ibclient.ReleaseAddressByRef(ref, addr, logger)
+	switch {
+	case errors.Is(err, infoblox.ErrNotFound):
+		logger.Info("did not find host record, releasing address by hostname")
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to release address %s from host record %s: %w", addr, ref, err)
+	}
+	logger.Info("released address from host record")
+	h.recorder.Eventf(h.claim, corev1.EventTypeNormal, AddressReleasedEventReason, "Released address %s from host record %s", addr, ref)
+	return true, nil
+}
+
+// quarantineReleasedAddress reserves the released address stored on the claim for the quarantine period of the pool.
+// Claims allocated by older versions don't store their address, so their address is not quarantined.
+func (h *InfobloxClaimHandler) quarantineReleasedAddress(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	addr, err := netip.ParseAddr(h.claim.Annotations[addressAnnotation])
+	if err != nil {
+		return nil
+	}
+	if _, ok := subnetContaining(h.pool, addr); !ok {
+		return nil
+	}
+
+	if err := quarantineReleasedAddress(h.ibclient, h.pool, addr, cmp.Or(h.claim.Annotations[hostnameAnnotation], h.claim.Name), logger); err != nil {
+		h.recorder.Eventf(h.claim, corev1.EventTypeWarning, QuarantineFailedEventReason, "Could not quarantine released address %s: %v", addr, err)
+		return err
+	}
+	return nil
+}
+
+// releaseAddressByHostname releases the addresses of the hostname of the claim in all subnets of the pool.
+func (h *InfobloxClaimHandler) releaseAddressByHostname(ctx context.Context) (bool, error) {
+	logger := log.FromContext(ctx)
+
 	hostName, err := h.getHostname(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get hostname: %w", err)
+		return false, fmt.Errorf("failed to get hostname: %w", err)
 	}
 
 	logger = logger.WithValues("hostname", hostName)
 
-	if len(h.pool.Spec.Subnets) == 0 || h.pool == nil {
-		return nil, fmt.Errorf("no subnets found in pool or pool not found")
+	if h.pool == nil || len(poolSubnets(h.pool)) == 0 {
+		return false, fmt.Errorf("no subnets found in pool or pool not found")
 	}
 
 	var subnet netip.Prefix
-	for _, sub := range h.pool.Spec.Subnets {
+	released := false
+	for _, sub := range poolSubnets(h.pool) {
 		subnet, err = netip.ParsePrefix(sub.CIDR)
 		if err != nil {
 			logger.Error(err, "failed to parse subnet", "subnet", sub)
@@ -228,20 +572,72 @@ func (h *InfobloxClaimHandler) ReleaseAddress(ctx context.Context) (*ctrl.Result
 			continue
 		}
 
-		dnsView := determineDNSView(h.pool.Spec.DNSView, h.ibclient.GetHostConfig().DefaultDNSView, h.pool.Spec.NetworkView)
-		err = h.ibclient.ReleaseAddress(h.pool.Spec.NetworkView, dnsView, subnet, hostName, logger)
-		if err != nil {
-			// since ibclient.NotFoundError has a pointer receiver on it's Error() method, we can't use errors.As() here.
-			if _, ok := err.(*ibclient.NotFoundError); !ok {
-				logger.Error(err, "failed to release address for host", "hostname", hostName)
-			}
+		dnsView := determineDNSView(h.pool.PoolSpec().DNSView, h.ibclient.GetHostConfig().DefaultDNSView, h.pool.PoolSpec().NetworkView)
+		err = h.ibclient.ReleaseAddress(h.pool.PoolSpec().NetworkView, dnsView, subnet, hostName, logger)
+		switch {
+		case errors.Is(err, infoblox.ErrNotFound):
 			logger.Info("did not find address for host", "hostname", hostName, "error", err)
-		} else {
+		case err != nil:
+			logger.Error(err, "failed to release address for host", "hostname", hostName)
+		default:
 			logger.Info("released address for host", "hostname", hostName)
+			h.recorder.Eventf(h.claim, corev1.EventTypeNormal, AddressReleasedEventReason, "Released address of hostname %s in subnet %s", hostName, subnet)
+			released = true
 		}
 	}
+	return released, nil
+}
 
-	return nil, nil
+// ensureNamespaceAllowed ensures that the namespace of the claim may use the pool and its instance.
+func (h *InfobloxClaimHandler) ensureNamespaceAllowed(ctx context.Context) error {
+	allowed, err := poolutil.NamespaceAllowed(ctx, h.Client, h.pool.PoolSpec().AllowedNamespaces, h.claim.Namespace)
+	if err != nil {
+		return err
+	}
+	message := "the namespace of the claim is not allowed to use the referenced pool"
+	if allowed {
+		allowed, err = instanceAllowsNamespace(ctx, h.Client, h.pool.PoolSpec().InstanceRef.Name, h.claim.Namespace)
+		if err != nil {
+			return err
+		}
+		message = "the namespace of the claim is not allowed to use the instance of the referenced pool"
+	}
+	if !allowed {
+		conditions.Set(h.claim, metav1.Condition{
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.NamespaceNotAllowedReason,
+			Message: message,
+		})
+		h.recorder.Event(h.claim, corev1.EventTypeWarning, v1alpha1.NamespaceNotAllowedReason, message)
+		return errors.New(message)
+	}
+	return nil
+}
+
+// ensureQuota ensures that allocating an address for the claim does not exceed the quota of the pool.
+// Claims that already hold an address are not checked again.
+func (h *InfobloxClaimHandler) ensureQuota(ctx context.Context) error {
+	quota := h.pool.PoolSpec().Quota
+	if quota == (v1alpha1.Quota{}) || h.claim.Status.AddressRef.Name != "" {
+		return nil
+	}
+
+	claims, err := listClaimsHoldingAddresses(ctx, h.Client, h.pool)
+	if err != nil {
+		return err
+	}
+	if err := checkQuota(h.claim, claims, quota); err != nil {
+		conditions.Set(h.claim, metav1.Condition{
+			Type:    clusterv1.ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.QuotaExceededReason,
+			Message: err.Error(),
+		})
+		h.recorder.Event(h.claim, corev1.EventTypeWarning, v1alpha1.QuotaExceededReason, err.Error())
+		return err
+	}
+	return nil
 }
 
 // GetPool returns local pool.
@@ -265,8 +661,8 @@ func (h *InfobloxClaimHandler) ensureHostname(ctx context.Context) (string, erro
 	h.claim.Annotations[hostnameAnnotation] = hostname
 
 	// ensure that the hostnames suffix matches the given zone
-	if !strings.HasSuffix(hostname, h.pool.Spec.DNSZone) {
-		return "", fmt.Errorf("hostname %q must have DNS zone %q as suffix", hostname, h.pool.Spec.DNSZone)
+	if !strings.HasSuffix(hostname, h.pool.PoolSpec().DNSZone) {
+		return "", fmt.Errorf("hostname %q must have DNS zone %q as suffix", hostname, h.pool.PoolSpec().DNSZone)
 	}
 
 	return hostname, nil
@@ -279,7 +675,7 @@ func (h *InfobloxClaimHandler) getHostname(ctx context.Context) (string, error)
 		return hostName, nil
 	}
 
-	if h.pool.Spec.DNSZone == "" {
+	if h.pool.PoolSpec().DNSZone == "" {
 		return h.claim.Name, nil
 	}
 
@@ -293,8 +689,8 @@ func (h *InfobloxClaimHandler) getHostname(ctx context.Context) (string, error)
 		return "", err
 	}
 
-	if h.pool.Spec.DNSZone != "" {
-		hostName += "." + h.pool.Spec.DNSZone
+	if h.pool.PoolSpec().DNSZone != "" {
+		hostName += "." + h.pool.PoolSpec().DNSZone
 	}
 
 	return hostName, nil
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"net/netip"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/mock/gomock"
@@ -34,6 +35,7 @@ import (
 	"sigs.k8s.io/cluster-api-ipam-provider-in-cluster/pkg/ipamutil"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -140,15 +142,16 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			It("should allocate an Address from the Pool", func() {
 				addr, err := netip.ParseAddr("10.0.0.2")
 				Expect(err).NotTo(HaveOccurred())
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
 				expectedIPAddress = ipamv1.IPAddress{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:       claimName,
-						Namespace:  namespace,
-						Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+						Name:        claimName,
+						Namespace:   namespace,
+						Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+						Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 						OwnerReferences: []metav1.OwnerReference{
 							{
 								APIVersion:         ipamAPIVersion,
@@ -187,6 +190,180 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 					WithTimeout(1 * time.Second).WithPolling(100 * time.Millisecond).Should(
 					EqualObject(&expectedIPAddress, IgnoreAutogeneratedMetadata, IgnoreUIDsOnIPAddress),
 				)
+
+				Eventually(ObjectList(&corev1.EventList{}, client.InNamespace(namespace))).Should(
+					HaveField("Items", ContainElement(And(
+						HaveField("InvolvedObject.Name", claimName),
+						HaveField("Reason", v1alpha1.AddressAllocatedReason),
+						HaveField("Message", "Allocated address 10.0.0.2 for hostname test-claim in subnet 10.0.0.0/24"),
+					))),
+				)
+			})
+
+			It("should record the host record on the Address and the claim and release the address by reference", func() {
+				allocation := infoblox.Allocation{
+					Address:     netip.MustParseAddr("10.0.0.2"),
+					Ref:         "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+					Name:        "test-claim",
+					NetworkView: "default",
+				}
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).Return(allocation, nil).AnyTimes()
+				// ReleaseAddress must not be called, since the host record is released by its reference.
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(allocation.Ref, allocation.Address, gomock.Any()).Return(nil).MinTimes(1)
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+
+				Eventually(findAddress(claimName, namespace)).Should(
+					HaveField("Annotations", Equal(map[string]string{
+						hostRecordRefAnnotation: allocation.Ref,
+						fqdnAnnotation:          "test-claim",
+						networkViewAnnotation:   "default",
+						subnetAnnotation:        "10.0.0.0/24",
+					})),
+				)
+				Eventually(Object(&claim)).Should(SatisfyAll(
+					HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, allocation.Ref)),
+					HaveField("Annotations", HaveKeyWithValue(addressAnnotation, "10.0.0.2")),
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", clusterv1.ReadyCondition),
+						HaveField("Status", metav1.ConditionTrue),
+						HaveField("Message", `allocated 10.0.0.2 from subnet 10.0.0.0/24 in network view "default" for host record "test-claim" (`+allocation.Ref+`)`),
+					))),
+				))
+			})
+
+			It("should release the address by hostname if the referenced host record does not exist", func() {
+				allocation := infoblox.Allocation{
+					Address: netip.MustParseAddr("10.0.0.2"),
+					Ref:     "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+				}
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).Return(infoblox.Allocation{}, infoblox.ErrNotFound).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(allocation.Ref, allocation.Address, gomock.Any()).Return(errors.Wrap(infoblox.ErrNotFound, "failed to get Infoblox host record")).MinTimes(1)
+				localInfobloxClientMock.EXPECT().ReleaseAddress("default", gomock.Any(), gomock.Any(), claimName, gomock.Any()).Return(nil).MinTimes(1)
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(Object(&claim)).Should(HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, allocation.Ref)))
+			})
+
+			It("should retain the address of a deleted claim and reuse it for a new claim with the same hostname", func() {
+				allocation := infoblox.Allocation{
+					Address: netip.MustParseAddr("10.0.1.5"),
+					Ref:     "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+					Name:    "test-claim",
+				}
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), claimName, gomock.Any(), gomock.Any()).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).Return(allocation, nil).AnyTimes()
+				// The host record must not be released while the address is retained.
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+				Expect(Update(&pool, func() {
+					pool.Spec.Retention = v1alpha1.Retention{Period: metav1.Duration{Duration: time.Hour}}
+				})()).To(Succeed())
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(findAddress(claimName, namespace)).Should(HaveField("Spec.Address", "10.0.1.5"))
+
+				deleteClaim(claimName, namespace)
+				Eventually(Object(&pool)).Should(HaveField("Status.RetainedAddresses", ConsistOf(SatisfyAll(
+					HaveField("Hostname", claimName),
+					HaveField("Address", "10.0.1.5"),
+					HaveField("Subnet", "10.0.1.0/24"),
+					HaveField("HostRecordRef", allocation.Ref),
+				))))
+
+				claim = newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(findAddress(claimName, namespace)).Should(HaveField("Spec.Address", "10.0.1.5"))
+				Eventually(Object(&pool)).Should(HaveField("Status.RetainedAddresses", BeEmpty()))
+			})
+
+			It("should quarantine the released address of a deleted claim", func() {
+				allocation := infoblox.Allocation{
+					Address: netip.MustParseAddr("10.0.0.2"),
+					Ref:     "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+				}
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(allocation.Ref, allocation.Address, gomock.Any()).Return(nil).MinTimes(1)
+				localInfobloxClientMock.EXPECT().QuarantineAddress("default", allocation.Address, claimName, namespace+"/"+poolName, gomock.Any(), gomock.Any()).Return(nil).MinTimes(1)
+
+				Expect(Update(&pool, func() {
+					pool.Spec.Quarantine = v1alpha1.Quarantine{Period: metav1.Duration{Duration: 10 * time.Minute}}
+				})()).To(Succeed())
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(Object(&claim)).Should(HaveField("Annotations", HaveKeyWithValue(addressAnnotation, "10.0.0.2")))
+			})
+
+			It("should adopt the host record stored on a claim moved by clusterctl move", func() {
+				allocation := infoblox.Allocation{
+					Address:     netip.MustParseAddr("10.0.1.5"),
+					Ref:         "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+					Name:        "test-claim",
+					NetworkView: "default",
+				}
+				var lookups atomic.Int32
+				adopt := localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).DoAndReturn(func(string, netip.Addr) (infoblox.Allocation, error) {
+					lookups.Add(1)
+					return allocation, nil
+				}).MinTimes(1)
+				// The host record must be adopted instead of looking it up by hostname. Once the address has been allocated,
+				// the host record is found by its hostname.
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), "test-claim", gomock.Any(), gomock.Any()).Return(allocation, nil).After(adopt).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(allocation.Ref, allocation.Address, gomock.Any()).Return(nil).AnyTimes()
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				claim.Annotations = map[string]string{
+					hostnameAnnotation:      "test-claim",
+					hostRecordRefAnnotation: allocation.Ref,
+					addressAnnotation:       "10.0.1.5",
+				}
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+
+				Eventually(findAddress(claimName, namespace)).Should(SatisfyAll(
+					HaveField("Spec.Address", "10.0.1.5"),
+					HaveField("Spec.Gateway", "10.0.1.1"),
+					HaveField("Annotations", HaveKeyWithValue(subnetAnnotation, "10.0.1.0/24")),
+					HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, allocation.Ref)),
+				))
+
+				// The host record is not looked up again when the claim is reconciled after the address has been allocated.
+				Expect(Update(&claim, func() {
+					claim.Labels = map[string]string{"test": "reconcile"}
+				})()).To(Succeed())
+				Eventually(Object(&claim)).Should(HaveField("Labels", HaveKeyWithValue("test", "reconcile")))
+				count := lookups.Load()
+				Expect(Update(&claim, func() {
+					claim.Labels["test"] = "reconcile-again"
+				})()).To(Succeed())
+				Consistently(lookups.Load, time.Second).Should(Equal(count))
+			})
+
+			It("should keep the host record of a claim deleted by clusterctl move", func() {
+				allocation := infoblox.Allocation{
+					Address: netip.MustParseAddr("10.0.0.2"),
+					Ref:     "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default",
+					Name:    "test-claim",
+				}
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetAddressByRef(allocation.Ref, allocation.Address).Return(allocation, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddressByRef(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(Object(&claim)).Should(HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, allocation.Ref)))
+
+				Expect(Update(&claim, func() {
+					claim.Annotations[clusterctlv1.DeleteForMoveAnnotation] = ""
+				})()).To(Succeed())
 			})
 
 			It("should allocate an Address from second subnet if there are no available addresses in first subnet", func() {
@@ -196,16 +373,17 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 				Expect(err).NotTo(HaveOccurred())
 				addr, err := netip.ParseAddr("10.0.1.2")
 				Expect(err).NotTo(HaveOccurred())
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), subnet0, gomock.Any(), gomock.Any(), gomock.Any()).Return(netip.Addr{}, errors.New("no available addresses")).AnyTimes()
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), subnet1, gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), subnet0, gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{}, errors.New("no available addresses")).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), subnet1, gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
 				expectedIPAddress = ipamv1.IPAddress{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:       claimName,
-						Namespace:  namespace,
-						Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+						Name:        claimName,
+						Namespace:   namespace,
+						Annotations: map[string]string{subnetAnnotation: "10.0.1.0/24"},
+						Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 						OwnerReferences: []metav1.OwnerReference{
 							{
 								APIVersion:         ipamAPIVersion,
@@ -247,6 +425,223 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			})
 		})
 
+		When("the referenced global pool exists", func() {
+			const poolName = "test-global-pool"
+			const claimName = "test-claim"
+
+			BeforeEach(func() {
+				localInfobloxClientMock = ibmock.NewMockClient(mockCtrl)
+				localInfobloxClientMock.EXPECT().GetHostConfig().Return(&infoblox.HostConfig{}).AnyTimes()
+				getInfobloxClientForInstanceFunc = mockGetInfobloxClientForInstance
+				pool := v1alpha1.GlobalInfobloxIPPool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: poolName,
+					},
+					Spec: v1alpha1.InfobloxIPPoolSpec{
+						InstanceRef: v1alpha1.InstanceReference{Name: instanceName},
+						Subnets: []v1alpha1.Subnet{
+							{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"},
+						},
+						NetworkView: "default",
+					},
+				}
+				Expect(k8sClient.Create(context.Background(), &pool)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				deleteClaim(claimName, namespace)
+				deleteGlobalPool(poolName)
+				getInfobloxClientForInstanceFunc = getInfobloxClientForInstance
+			})
+
+			It("should allocate an Address from the Pool", func() {
+				addr, err := netip.ParseAddr("10.0.0.2")
+				Expect(err).NotTo(HaveOccurred())
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+				claim := newClaim(claimName, namespace, "GlobalInfobloxIPPool", poolName)
+				expectedIPAddress := ipamv1.IPAddress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        claimName,
+						Namespace:   namespace,
+						Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+						Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
+						OwnerReferences: []metav1.OwnerReference{
+							{
+								APIVersion:         ipamAPIVersion,
+								BlockOwnerDeletion: ptr.To(true),
+								Controller:         ptr.To(true),
+								Kind:               "IPAddressClaim",
+								Name:               claimName,
+							},
+							{
+								APIVersion:         "ipam.cluster.x-k8s.io/v1alpha1",
+								BlockOwnerDeletion: ptr.To(true),
+								Controller:         ptr.To(false),
+								Kind:               "GlobalInfobloxIPPool",
+								Name:               poolName,
+							},
+						},
+					},
+					Spec: ipamv1.IPAddressSpec{
+						ClaimRef: ipamv1.IPAddressClaimReference{
+							Name: claimName,
+						},
+						PoolRef: ipamv1.IPPoolReference{
+							APIGroup: "ipam.cluster.x-k8s.io",
+							Kind:     "GlobalInfobloxIPPool",
+							Name:     poolName,
+						},
+						Address: "10.0.0.2",
+						Prefix:  ptr.To[int32](24),
+						Gateway: "10.0.0.1",
+					},
+				}
+
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+
+				Eventually(findAddress(claimName, namespace)).
+					WithTimeout(1 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					EqualObject(&expectedIPAddress, IgnoreAutogeneratedMetadata, IgnoreUIDsOnIPAddress),
+				)
+			})
+		})
+
+		When("the referenced global pool does not allow the namespace of the claim", func() {
+			const poolName = "test-restricted-global-pool"
+			const claimName = "test-claim"
+
+			BeforeEach(func() {
+				localInfobloxClientMock = ibmock.NewMockClient(mockCtrl)
+				getInfobloxClientForInstanceFunc = mockGetInfobloxClientForInstance
+				pool := v1alpha1.GlobalInfobloxIPPool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: poolName,
+					},
+					Spec: v1alpha1.InfobloxIPPoolSpec{
+						InstanceRef: v1alpha1.InstanceReference{Name: instanceName},
+						Subnets: []v1alpha1.Subnet{
+							{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"},
+						},
+						NetworkView: "default",
+						AllowedNamespaces: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"infoblox.ipam.cluster.x-k8s.io/allowed": "true"},
+						},
+					},
+				}
+				Expect(k8sClient.Create(context.Background(), &pool)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				deleteClaim(claimName, namespace)
+				deleteGlobalPool(poolName)
+				getInfobloxClientForInstanceFunc = getInfobloxClientForInstance
+			})
+
+			It("should not allocate an Address and report the reason on the claim", func() {
+				claim := newClaim(claimName, namespace, "GlobalInfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+
+				Eventually(Object(&claim)).
+					WithTimeout(5 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					HaveField("Status.Conditions", ContainElement(And(
+						HaveField("Type", clusterv1.ReadyCondition),
+						HaveField("Reason", v1alpha1.NamespaceNotAllowedReason),
+					))))
+
+				addresses := ipamv1.IPAddressList{}
+				Consistently(ObjectList(&addresses, client.InNamespace(namespace))).
+					WithTimeout(2 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					HaveField("Items", HaveLen(0)))
+			})
+
+			It("should release the address of a claim whose namespace is not allowed anymore", func() {
+				localInfobloxClientMock.EXPECT().GetHostConfig().Return(&infoblox.HostConfig{}).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: netip.MustParseAddr("10.0.0.2")}, nil).AnyTimes()
+				// The address is released when the claim is deleted after this test.
+				localInfobloxClientMock.EXPECT().ReleaseAddress("default", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).MinTimes(1)
+
+				pool := v1alpha1.GlobalInfobloxIPPool{ObjectMeta: metav1.ObjectMeta{Name: poolName}}
+				var allowedNamespaces *metav1.LabelSelector
+				Expect(Update(&pool, func() {
+					allowedNamespaces = pool.Spec.AllowedNamespaces
+					pool.Spec.AllowedNamespaces = nil
+				})()).To(Succeed())
+
+				claim := newClaim(claimName, namespace, "GlobalInfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+				Eventually(findAddress(claimName, namespace)).Should(HaveField("Spec.Address", "10.0.0.2"))
+
+				Expect(Update(&pool, func() {
+					pool.Spec.AllowedNamespaces = allowedNamespaces
+				})()).To(Succeed())
+				Eventually(Object(&claim)).Should(HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", clusterv1.ReadyCondition),
+					HaveField("Reason", v1alpha1.NamespaceNotAllowedReason),
+				))))
+			})
+		})
+
+		When("the referenced namespaced pool has a namespace quota", func() {
+			const poolName = "test-quota-pool"
+
+			BeforeEach(func() {
+				localInfobloxClientMock = ibmock.NewMockClient(mockCtrl)
+				localInfobloxClientMock.EXPECT().GetHostConfig().Return(&infoblox.HostConfig{}).AnyTimes()
+				getInfobloxClientForInstanceFunc = mockGetInfobloxClientForInstance
+				pool := v1alpha1.InfobloxIPPool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      poolName,
+						Namespace: namespace,
+					},
+					Spec: v1alpha1.InfobloxIPPoolSpec{
+						InstanceRef: v1alpha1.InstanceReference{Name: instanceName},
+						Subnets: []v1alpha1.Subnet{
+							{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"},
+						},
+						NetworkView: "default",
+						Quota:       v1alpha1.Quota{PerNamespace: 1},
+					},
+				}
+				Expect(k8sClient.Create(context.Background(), &pool)).To(Succeed())
+				Eventually(Get(&pool)).Should(Succeed())
+			})
+
+			AfterEach(func() {
+				deleteClaim("test-quota-1", namespace)
+				deleteClaim("test-quota-2", namespace)
+				deleteNamespacedPool(poolName, namespace)
+				getInfobloxClientForInstanceFunc = getInfobloxClientForInstance
+			})
+
+			It("should not allocate more addresses than the quota allows", func() {
+				addr, err := netip.ParseAddr("10.0.0.2")
+				Expect(err).NotTo(HaveOccurred())
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), "test-quota-1", gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+				first := newClaim("test-quota-1", namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &first)).To(Succeed())
+				Eventually(Object(&first)).
+					WithTimeout(5 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					HaveField("Status.AddressRef.Name", Equal("test-quota-1")))
+
+				second := newClaim("test-quota-2", namespace, "InfobloxIPPool", poolName)
+				Expect(k8sClient.Create(context.Background(), &second)).To(Succeed())
+				Eventually(Object(&second)).
+					WithTimeout(5 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					HaveField("Status.Conditions", ContainElement(And(
+						HaveField("Type", clusterv1.ReadyCondition),
+						HaveField("Reason", v1alpha1.QuotaExceededReason),
+					))))
+				addresses := ipamv1.IPAddressList{}
+				Consistently(ObjectList(&addresses, client.InNamespace(namespace))).
+					WithTimeout(2 * time.Second).WithPolling(100 * time.Millisecond).Should(
+					HaveField("Items", HaveLen(1)))
+			})
+		})
+
 		When("the referenced namespaced pool does not define gateway for subnet", func() {
 			const poolName = "test-pool"
 			const claimName = "test-claim"
@@ -286,15 +681,16 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			It("should allocate an Address from the Pool", func() {
 				addr, err := netip.ParseAddr("10.0.0.2")
 				Expect(err).NotTo(HaveOccurred())
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 				claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
 				expectedIPAddress = ipamv1.IPAddress{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:       claimName,
-						Namespace:  namespace,
-						Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+						Name:        claimName,
+						Namespace:   namespace,
+						Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+						Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 						OwnerReferences: []metav1.OwnerReference{
 							{
 								APIVersion:         ipamAPIVersion,
@@ -414,7 +810,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 				It("should not create an IPAddress for claims until the pool is unpaused", func() {
 					addr, err := netip.ParseAddr("10.0.0.2")
 					Expect(err).NotTo(HaveOccurred())
-					localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+					localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 					localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 					tmpPool := &v1alpha1.InfobloxIPPool{}
@@ -477,7 +873,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 				It("should prevent deletion of claims", func() {
 					addr, err := netip.ParseAddr("10.0.0.2")
 					Expect(err).NotTo(HaveOccurred())
-					localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+					localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 					localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 					claim := newClaim("paused-pool-delete-claim-test", namespace, "InfobloxIPPool", poolName)
@@ -551,7 +947,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 		It("should add the owner references and finalizer", func() {
 			addr, err := netip.ParseAddr("10.0.0.2")
 			Expect(err).NotTo(HaveOccurred())
-			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 			localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 			addressSpec := ipamv1.IPAddressSpec{
@@ -583,9 +979,10 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 
 			expectedIPAddress := ipamv1.IPAddress{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test",
-					Namespace:  namespace,
-					Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+					Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 					OwnerReferences: []metav1.OwnerReference{
 						{
 							APIVersion:         ipamAPIVersion,
@@ -646,7 +1043,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 		It("should add the owner references and finalizer", func() {
 			addr, err := netip.ParseAddr("10.0.0.2")
 			Expect(err).NotTo(HaveOccurred())
-			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 			localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 			addressSpec := ipamv1.IPAddressSpec{
@@ -685,9 +1082,10 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 
 			expectedIPAddress := ipamv1.IPAddress{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test",
-					Namespace:  namespace,
-					Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+					Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 					OwnerReferences: []metav1.OwnerReference{
 						{
 							APIVersion: "alpha-dummy",
@@ -933,7 +1331,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			It("allocates an ipaddress upon updating a cluster when removing spec.paused", func() {
 				addr, err := netip.ParseAddr("10.0.0.2")
 				Expect(err).NotTo(HaveOccurred())
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 				cluster = clusterv1.Cluster{
@@ -972,7 +1370,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			It("allocates an ipaddress upon updating a cluster when removing the paused annotation", func() {
 				addr, err := netip.ParseAddr("10.0.0.2")
 				Expect(err).NotTo(HaveOccurred())
-				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+				localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 				localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 				cluster = clusterv1.Cluster{
@@ -1083,7 +1481,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 		It("does not allocate an ipaddress for the claim until the ip address claim is unpaused", func() {
 			addr, err := netip.ParseAddr("10.0.0.2")
 			Expect(err).NotTo(HaveOccurred())
-			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(addr, nil).AnyTimes()
+			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{Address: addr}, nil).AnyTimes()
 			localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 			claim := newClaim("test", namespace, "InfobloxIPPool", poolName)
@@ -1106,9 +1504,10 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 
 			expectedIPAddress := ipamv1.IPAddress{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test",
-					Namespace:  namespace,
-					Finalizers: []string{ipamutil.ProtectAddressFinalizer},
+					Name:        "test",
+					Namespace:   namespace,
+					Annotations: map[string]string{subnetAnnotation: "10.0.0.0/24"},
+					Finalizers:  []string{ipamutil.ProtectAddressFinalizer},
 					OwnerReferences: []metav1.OwnerReference{
 						{
 							APIVersion:         ipamAPIVersion,
@@ -1182,7 +1581,7 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 		})
 
 		It("should not allocate an Address if there are no addresses available", func() {
-			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(netip.Addr{}, errors.New("no available addresses")).AnyTimes()
+			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{}, errors.New("no available addresses")).AnyTimes()
 
 			claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
 
@@ -1191,6 +1590,30 @@ var _ = Describe("IPAddressClaimReconciler", func() {
 			_, err := findAddress(claimName, namespace)()
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("should report exhausted subnets on the claim and the pool", func() {
+			exhausted := errors.Wrap(infoblox.ErrRangeExhausted, "failed to create or update Infoblox host record")
+			localInfobloxClientMock.EXPECT().GetOrAllocateAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.Allocation{}, exhausted).AnyTimes()
+			localInfobloxClientMock.EXPECT().ReleaseAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			claim := newClaim(claimName, namespace, "InfobloxIPPool", poolName)
+			Expect(k8sClient.Create(context.Background(), &claim)).To(Succeed())
+			defer deleteClaim(claimName, namespace)
+
+			Eventually(Object(&claim)).
+				WithTimeout(5 * time.Second).WithPolling(100 * time.Millisecond).Should(
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", clusterv1.ReadyCondition),
+					HaveField("Reason", v1alpha1.SubnetExhaustedReason),
+				))))
+			Eventually(Object(&v1alpha1.InfobloxIPPool{ObjectMeta: metav1.ObjectMeta{Name: poolName, Namespace: namespace}})).
+				WithTimeout(5 * time.Second).WithPolling(100 * time.Millisecond).Should(
+				HaveField("Status.Conditions", ContainElement(And(
+					HaveField("Type", v1alpha1.AddressesAvailableCondition),
+					HaveField("Status", metav1.ConditionFalse),
+					HaveField("Reason", v1alpha1.SubnetExhaustedReason),
+				))))
+		})
 	})
 })
 
@@ -1228,6 +1651,17 @@ func deleteNamespacedPool(name, namespace string) {
 	EventuallyWithOffset(1, Get(&pool)).Should(Not(Succeed()))
 }
 
+func deleteGlobalPool(name string) {
+	defer GinkgoRecover()
+	pool := v1alpha1.GlobalInfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	ExpectWithOffset(1, k8sClient.Delete(context.Background(), &pool)).To(Succeed())
+	EventuallyWithOffset(1, Get(&pool)).Should(Not(Succeed()))
+}
+
 func deleteClaim(name, namespace string) {
 	defer GinkgoRecover()
 	claim := ipamv1.IPAddressClaim{
@@ -1251,6 +1685,6 @@ func findAddress(name, namespace string) func() (client.Object, error) {
 	return Object(&address)
 }
 
-func mockGetInfobloxClientForInstance(_ context.Context, _ client.Reader, _, _ string, _ func(infoblox.Config) (infoblox.Client, error)) (infoblox.Client, error) {
+func mockGetInfobloxClientForInstance(_ context.Context, _ client.Reader, _, _ string, _ *CredentialSources, _ *RateLimiters, _ func(infoblox.Config) (infoblox.Client, error)) (infoblox.Client, error) {
 	return localInfobloxClientMock, nil
 }
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// isDeletedForMove returns whether clusterctl move deletes an object from the source cluster after creating it in the
+// target cluster. The host records and networks of such objects are kept in Infoblox, since the target cluster adopts them.
+func isDeletedForMove(obj client.Object) bool {
+	_, ok := obj.GetAnnotations()[clusterctlv1.DeleteForMoveAnnotation]
+	return ok
+}
+
+// reconcileDeleteForMove removes the finalizer of a pool deleted by clusterctl move, even if claims still reference it.
+// Its retained and quarantined addresses and its network are not released, since the pool in the target cluster adopts them.
+// The retained addresses are restored from the annotation of the pool, see restoreRetainedAddresses.
+func (r *genericPoolReconciler) reconcileDeleteForMove(ctx context.Context, pool v1alpha1.GenericInfobloxPool) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if pool.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+	base, ok := pool.DeepCopyObject().(client.Object)
+	if !ok {
+		return ctrl.Result{}, fmt.Errorf("unexpected pool type %T", pool)
+	}
+	if controllerutil.RemoveFinalizer(pool, ProtectPoolFinalizer) {
+		if err := r.client.Patch(ctx, pool, client.MergeFrom(base)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+		}
+		logger.Info("removed finalizer of pool deleted by clusterctl move, keeping its addresses and network in Infoblox")
+	}
+	return ctrl.Result{}, nil
+}
+
+// adoptedAddress returns the address of the host record stored on the claim, e.g. after the claim was moved to this cluster
+// by clusterctl move, so the claim keeps its host record instead of looking it up by hostname or allocating a new address.
+// Host records that don't exist anymore, were renamed or don't contain an address of the pool are not adopted.
+func (h *InfobloxClaimHandler) adoptedAddress(ctx context.Context, hostName string) (infoblox.Allocation, bool) {
+	logger := log.FromContext(ctx)
+
+	ref := h.claim.Annotations[hostRecordRefAnnotation]
+	addr, err := netip.ParseAddr(h.claim.Annotations[addressAnnotation])
+	if ref == "" || err != nil {
+		return infoblox.Allocation{}, false
+	}
+	if _, ok := subnetContaining(h.pool, addr); !ok {
+		return infoblox.Allocation{}, false
+	}
+
+	allocation, err := h.ibclient.GetAddressByRef(ref, addr)
+	if err != nil {
+		if !errors.Is(err, infoblox.ErrNotFound) {
+			logger.Error(err, "failed to get host record of claim, looking it up by hostname", "hostRecord", ref)
+		}
+		return infoblox.Allocation{}, false
+	}
+	if allocation.Name != hostName {
+		logger.Info("host record of claim has been renamed, looking it up by hostname", "hostRecord", ref, "name", allocation.Name)
+		return infoblox.Allocation{}, false
+	}
+	return allocation, true
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// quarantineReleasedAddress reserves a released IPv4 address for the quarantine period of the pool. Infoblox rejects
+// reservations of addresses that are still used by a host record, so the address has to be released first. Failed
+// reservations are retried, and the error is returned if they keep failing, so the release is requeued instead of
+// skipping the quarantine. Addresses that have been allocated again in the meantime belong to another host and are not reserved.
+func quarantineReleasedAddress(ibclient infoblox.Client, pool v1alpha1.GenericInfobloxPool, addr netip.Addr, name string, logger logr.Logger) error {
+	period := pool.PoolSpec().Quarantine.Period.Duration
+	if period <= 0 || !addr.Is4() {
+		return nil
+	}
+
+	networkView := cmp.Or(pool.PoolSpec().NetworkView, ibclient.GetHostConfig().DefaultNetworkView)
+	until := time.Now().Add(period)
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool { return errors.Is(err, infoblox.ErrTransient) }, func() error {
+		return ibclient.QuarantineAddress(networkView, addr, name, networkOwner(pool), until, logger)
+	})
+	switch {
+	case errors.Is(err, infoblox.ErrConflict):
+		logger.Info("address has been allocated again, not quarantining it", "address", addr)
+	case err != nil:
+		return fmt.Errorf("failed to quarantine address %s: %w", addr, err)
+	default:
+		logger.Info("quarantined address", "address", addr, "until", until)
+	}
+	return nil
+}
+
+// releaseQuarantinedAddresses deletes the reservations of quarantined addresses of a pool once their quarantine ended,
+// or all of them if the pool is being deleted. It returns when the pool should be checked again. Since claims quarantine
+// addresses without notifying the pool, pools with a quarantine period are checked at least once per period.
+func (r *genericPoolReconciler) releaseQuarantinedAddresses(ctx context.Context, pool v1alpha1.GenericInfobloxPool, now time.Time) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+	spec := pool.PoolSpec()
+
+	period := spec.Quarantine.Period.Duration
+	if period <= 0 {
+		return 0, nil
+	}
+
+	ibclient, err := getInfobloxClientForInstance(ctx, r.client, spec.InstanceRef.Name, r.operatorNamespace, r.credentialSources, r.rateLimiters, r.newClientFunc(pool))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get infoblox client: %w", err)
+	}
+
+	networkView := cmp.Or(spec.NetworkView, ibclient.GetHostConfig().DefaultNetworkView)
+	if !pool.GetDeletionTimestamp().IsZero() {
+		_, err := ibclient.ReleaseQuarantinedAddresses(networkView, networkOwner(pool), time.Time{}, logger)
+		return 0, err
+	}
+
+	next, err := ibclient.ReleaseQuarantinedAddresses(networkView, networkOwner(pool), now, logger)
+	if err != nil {
+		return 0, err
+	}
+	if next.IsZero() {
+		return period, nil
+	}
+	return min(max(next.Sub(now), time.Second), period), nil
+}
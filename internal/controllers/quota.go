@@ -0,0 +1,130 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/poolutil"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// quotaKey identifies the namespace or Cluster addresses are counted for.
+type quotaKey struct {
+	namespace string
+	cluster   string
+}
+
+// listClaimsHoldingAddresses returns the claims of a pool that currently hold an address.
+func listClaimsHoldingAddresses(ctx context.Context, c client.Client, pool v1alpha1.GenericInfobloxPool) ([]ipamv1.IPAddressClaim, error) {
+	poolRef := ipamv1.IPPoolReference{
+		APIGroup: v1alpha1.GroupVersion.Group,
+		Kind:     pool.GetObjectKind().GroupVersionKind().Kind,
+		Name:     pool.GetName(),
+	}
+	// cluster-scoped pools have an empty namespace, so claims from all namespaces are listed
+	claims, err := poolutil.ListClaimsReferencingPool(ctx, c, pool.GetNamespace(), poolRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claims of pool: %w", err)
+	}
+	return slices.DeleteFunc(claims, func(claim ipamv1.IPAddressClaim) bool {
+		return claim.Status.AddressRef.Name == ""
+	}), nil
+}
+
+// countAddresses counts the addresses held by claims per namespace and per Cluster.
+func countAddresses(claims []ipamv1.IPAddressClaim) map[quotaKey]int32 {
+	used := map[quotaKey]int32{}
+	for _, claim := range claims {
+		used[quotaKey{namespace: claim.Namespace}]++
+		if cluster := claim.Labels[clusterv1.ClusterNameLabel]; cluster != "" {
+			used[quotaKey{namespace: claim.Namespace, cluster: cluster}]++
+		}
+	}
+	return used
+}
+
+// quotaUsage converts address counts into the usage reported in the pool status.
+func quotaUsage(used map[quotaKey]int32, quota v1alpha1.Quota) []v1alpha1.QuotaUsage {
+	usage := []v1alpha1.QuotaUsage{}
+	for key, count := range used {
+		limit := quota.PerNamespace
+		if key.cluster != "" {
+			limit = quota.PerCluster
+		}
+		if limit == 0 {
+			continue
+		}
+		usage = append(usage, v1alpha1.QuotaUsage{
+			Namespace: key.namespace,
+			Cluster:   key.cluster,
+			Used:      count,
+			Limit:     limit,
+		})
+	}
+	slices.SortFunc(usage, func(a, b v1alpha1.QuotaUsage) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Cluster, b.Cluster))
+	})
+	return usage
+}
+
+// checkQuota returns an error if allocating another address for the claim would exceed the quota of the pool.
+func checkQuota(claim *ipamv1.IPAddressClaim, claims []ipamv1.IPAddressClaim, quota v1alpha1.Quota) error {
+	used := countAddresses(slices.DeleteFunc(claims, func(c ipamv1.IPAddressClaim) bool {
+		return c.Namespace == claim.Namespace && c.Name == claim.Name
+	}))
+
+	if quota.PerNamespace > 0 && used[quotaKey{namespace: claim.Namespace}] >= quota.PerNamespace {
+		return fmt.Errorf("namespace %q already holds %d of %d addresses allowed by the pool quota", claim.Namespace, used[quotaKey{namespace: claim.Namespace}], quota.PerNamespace)
+	}
+
+	cluster := claim.Labels[clusterv1.ClusterNameLabel]
+	if quota.PerCluster > 0 && cluster != "" {
+		key := quotaKey{namespace: claim.Namespace, cluster: cluster}
+		if used[key] >= quota.PerCluster {
+			return fmt.Errorf("cluster %q already holds %d of %d addresses allowed by the pool quota", cluster, used[key], quota.PerCluster)
+		}
+	}
+	return nil
+}
+
+// claimToPoolWithQuota returns a mapper that enqueues the pool of a claim, if the pool has a quota configured.
+func claimToPoolWithQuota(c client.Client, kind string, newPool func() v1alpha1.GenericInfobloxPool) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		claim, ok := o.(*ipamv1.IPAddressClaim)
+		if !ok || claim.Spec.PoolRef.APIGroup != v1alpha1.GroupVersion.Group || claim.Spec.PoolRef.Kind != kind {
+			return nil
+		}
+
+		pool := newPool()
+		key := client.ObjectKey{Name: claim.Spec.PoolRef.Name}
+		if kind == "InfobloxIPPool" {
+			key.Namespace = claim.Namespace
+		}
+		if err := c.Get(ctx, key, pool); err != nil || pool.PoolSpec().Quota == (v1alpha1.Quota{}) {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: key}}
+	}
+}
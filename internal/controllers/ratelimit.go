@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"sync"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiters holds the rate limiter of every InfobloxInstance with a rate limit. Clients are created for every reconcile,
+// so the limiters are kept here to apply the limit to all requests sent to an instance. A single cache is shared by all
+// controllers. A nil cache creates a new limiter for every client.
+type RateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiters returns an empty rate limiter cache.
+func NewRateLimiters() *RateLimiters {
+	return &RateLimiters{limiters: map[string]*rate.Limiter{}}
+}
+
+// get returns the rate limiter of an InfobloxInstance, or nil if requests are not limited.
+// An existing limiter is updated if the configured limit changed.
+func (l *RateLimiters) get(instance *v1alpha1.InfobloxInstance) *rate.Limiter {
+	config := instance.Spec.RateLimit
+	if config.QPS == 0 {
+		l.forget(instance.Name)
+		return nil
+	}
+	limit := rate.Limit(config.QPS)
+	burst := int(cmp.Or(config.Burst, config.QPS))
+	if l == nil {
+		return rate.NewLimiter(limit, burst)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters[instance.Name]
+	if !ok {
+		limiter = rate.NewLimiter(limit, burst)
+		l.limiters[instance.Name] = limiter
+		return limiter
+	}
+	if limiter.Limit() != limit {
+		limiter.SetLimit(limit)
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
+
+// forget removes the rate limiter of an InfobloxInstance that has been deleted.
+func (l *RateLimiters) forget(name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.limiters, name)
+}
+
+// retryConfigFor returns the retry configuration of an InfobloxInstance, using the defaults for unset fields.
+func retryConfigFor(instance *v1alpha1.InfobloxInstance) infoblox.RetryConfig {
+	config := instance.Spec.Retry
+	retry := infoblox.DefaultRetryConfig
+	if config.MaxRetries != nil {
+		retry.MaxRetries = int(*config.MaxRetries)
+	}
+	retry.InitialBackoff = cmp.Or(config.InitialBackoff.Duration, retry.InitialBackoff)
+	retry.MaxBackoff = cmp.Or(config.MaxBackoff.Duration, retry.MaxBackoff)
+	return retry
+}
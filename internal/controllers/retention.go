@@ -0,0 +1,261 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// retainedAddressesAnnotation stores the retained addresses of a pool in addition to its status,
+// since clusterctl move copies the annotations of a pool to the target cluster but not its status.
+const retainedAddressesAnnotation = "ipam.cluster.x-k8s.io/infoblox-retained-addresses"
+
+// patchRetainedAddresses updates the retained addresses of a pool in its annotation and its status.
+// An optimistic lock is used, since claims and the pool reconciler change the list concurrently.
+func patchRetainedAddresses(ctx context.Context, c client.Client, pool v1alpha1.GenericInfobloxPool, update func([]v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress) error {
+	retained := update(slices.Clone(pool.PoolStatus().RetainedAddresses))
+
+	// The annotation is patched first, so a retained address is not lost if the status can't be patched.
+	if err := patchRetainedAddressesAnnotation(ctx, c, pool, retained); err != nil {
+		return err
+	}
+
+	base, ok := pool.DeepCopyObject().(client.Object)
+	if !ok {
+		return errors.New("failed to copy pool")
+	}
+	pool.PoolStatus().RetainedAddresses = retained
+	if err := c.Status().Patch(ctx, pool, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		return fmt.Errorf("failed to update retained addresses of pool: %w", err)
+	}
+	return nil
+}
+
+// patchRetainedAddressesAnnotation stores the retained addresses in the annotation of a pool, and removes it if there are none.
+func patchRetainedAddressesAnnotation(ctx context.Context, c client.Client, pool v1alpha1.GenericInfobloxPool, retained []v1alpha1.RetainedAddress) error {
+	var value string
+	if len(retained) > 0 {
+		data, err := json.Marshal(retained)
+		if err != nil {
+			return fmt.Errorf("failed to marshal retained addresses: %w", err)
+		}
+		value = string(data)
+	}
+	if pool.GetAnnotations()[retainedAddressesAnnotation] == value {
+		return nil
+	}
+
+	base, ok := pool.DeepCopyObject().(client.Object)
+	if !ok {
+		return errors.New("failed to copy pool")
+	}
+	annotations := pool.GetAnnotations()
+	if value == "" {
+		delete(annotations, retainedAddressesAnnotation)
+	} else {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[retainedAddressesAnnotation] = value
+	}
+	pool.SetAnnotations(annotations)
+	if err := c.Patch(ctx, pool, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		return fmt.Errorf("failed to update retained addresses annotation of pool: %w", err)
+	}
+	return nil
+}
+
+// restoreRetainedAddresses restores the retained addresses of a pool from its annotation if its status doesn't list any,
+// e.g. after the pool has been moved to this cluster by clusterctl move.
+func restoreRetainedAddresses(ctx context.Context, c client.Client, pool v1alpha1.GenericInfobloxPool) error {
+	logger := log.FromContext(ctx)
+
+	value, ok := pool.GetAnnotations()[retainedAddressesAnnotation]
+	if !ok || len(pool.PoolStatus().RetainedAddresses) > 0 {
+		return nil
+	}
+	var retained []v1alpha1.RetainedAddress
+	if err := json.Unmarshal([]byte(value), &retained); err != nil {
+		// This can only happen if the annotation has been edited manually, so it is ignored.
+		logger.Error(err, "failed to parse retained addresses annotation of pool")
+		return nil
+	}
+	if len(retained) == 0 {
+		return nil
+	}
+
+	base, ok := pool.DeepCopyObject().(client.Object)
+	if !ok {
+		return errors.New("failed to copy pool")
+	}
+	pool.PoolStatus().RetainedAddresses = retained
+	if err := c.Status().Patch(ctx, pool, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{})); err != nil {
+		return fmt.Errorf("failed to restore retained addresses of pool: %w", err)
+	}
+	logger.Info("restored retained addresses of pool from its annotation", "count", len(retained))
+	return nil
+}
+
+// retainedAddressFor returns the address retained for a hostname, if any.
+func retainedAddressFor(pool v1alpha1.GenericInfobloxPool, hostname string) (v1alpha1.RetainedAddress, bool) {
+	i := slices.IndexFunc(pool.PoolStatus().RetainedAddresses, func(r v1alpha1.RetainedAddress) bool { return r.Hostname == hostname })
+	if i < 0 {
+		return v1alpha1.RetainedAddress{}, false
+	}
+	return pool.PoolStatus().RetainedAddresses[i], true
+}
+
+// withoutRetainedAddress returns a function that removes the address retained for a hostname.
+func withoutRetainedAddress(hostname string) func([]v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+	return func(retained []v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+		return slices.DeleteFunc(retained, func(r v1alpha1.RetainedAddress) bool { return r.Hostname == hostname })
+	}
+}
+
+// preferSubnet moves the subnet containing addr to the front, so an existing host record with the address,
+// e.g. a retained or adopted one, is found before an address is allocated from another subnet.
+func preferSubnet(subnets []v1alpha1.Subnet, addr netip.Addr) []v1alpha1.Subnet {
+	i := slices.IndexFunc(subnets, func(s v1alpha1.Subnet) bool {
+		subnet, err := netip.ParsePrefix(s.CIDR)
+		return err == nil && subnet.Contains(addr)
+	})
+	if i <= 0 {
+		return subnets
+	}
+	ordered := append([]v1alpha1.Subnet{subnets[i]}, subnets[:i]...)
+	return append(ordered, subnets[i+1:]...)
+}
+
+// subnetContaining returns the subnet of the pool that contains addr.
+func subnetContaining(pool v1alpha1.GenericInfobloxPool, addr netip.Addr) (netip.Prefix, bool) {
+	for _, sub := range poolSubnets(pool) {
+		subnet, err := netip.ParsePrefix(sub.CIDR)
+		if err == nil && subnet.Contains(addr) {
+			return subnet, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// releaseRetainedAddresses releases the retained addresses of a pool that have expired, or all of them if the pool is being deleted.
+// It returns the time until the next retained address expires, or zero if no addresses are retained anymore.
+func (r *genericPoolReconciler) releaseRetainedAddresses(ctx context.Context, pool v1alpha1.GenericInfobloxPool, now time.Time) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	releaseAll := !pool.GetDeletionTimestamp().IsZero()
+	var expired []v1alpha1.RetainedAddress
+	for _, retained := range pool.PoolStatus().RetainedAddresses {
+		if releaseAll || !now.Before(retained.ExpirationTime.Time) {
+			expired = append(expired, retained)
+		}
+	}
+
+	var errs []error
+	if len(expired) > 0 {
+		ibclient, err := getInfobloxClientForInstance(ctx, r.client, pool.PoolSpec().InstanceRef.Name, r.operatorNamespace, r.credentialSources, r.rateLimiters, r.newClientFunc(pool))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get infoblox client: %w", err)
+		}
+
+		var released []string
+		for _, retained := range expired {
+			err := r.releaseRetainedAddress(ibclient, pool, retained, logger)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			released = append(released, retained.Hostname)
+			r.recorder.Eventf(pool, corev1.EventTypeNormal, AddressReleasedEventReason, "Released retained address %s of hostname %s", retained.Address, retained.Hostname)
+		}
+
+		if len(released) > 0 {
+			err := patchRetainedAddresses(ctx, r.client, pool, func(retained []v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+				return slices.DeleteFunc(retained, func(r v1alpha1.RetainedAddress) bool { return slices.Contains(released, r.Hostname) })
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var requeueAfter time.Duration
+	for _, retained := range pool.PoolStatus().RetainedAddresses {
+		if d := retained.ExpirationTime.Sub(now); requeueAfter == 0 || d < requeueAfter {
+			requeueAfter = max(d, time.Second)
+		}
+	}
+	return requeueAfter, kerrors.NewAggregate(errs)
+}
+
+// releaseRetainedAddress releases a retained address from its host record. Host records that don't exist anymore are ignored.
+// Like the addresses of deleted claims, the address is quarantined if the pool has a quarantine period.
+func (r *genericPoolReconciler) releaseRetainedAddress(ibclient infoblox.Client, pool v1alpha1.GenericInfobloxPool, retained v1alpha1.RetainedAddress, logger logr.Logger) error {
+	addr, err := netip.ParseAddr(retained.Address)
+	if err != nil {
+		// This can only happen if the status has been edited manually, so the entry is dropped.
+		logger.Error(err, "failed to parse retained address", "address", retained.Address)
+		return nil
+	}
+
+	logger = logger.WithValues("hostname", retained.Hostname, "hostRecord", retained.HostRecordRef, "address", addr)
+	err = ibclient.ReleaseAddressByRef(retained.HostRecordRef, addr, logger)
+	switch {
+	case errors.Is(err, infoblox.ErrNotFound):
+		logger.Info("did not find host record of retained address")
+	case err != nil:
+		return fmt.Errorf("failed to release retained address %s of hostname %s: %w", addr, retained.Hostname, err)
+	default:
+		logger.Info("released retained address")
+	}
+
+	// The reservations of a deleted pool are released right after its retained addresses, so they are not quarantined.
+	// Otherwise, the entry is kept until the address is reserved, so the quarantine is retried if it fails.
+	if !pool.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	if err := quarantineReleasedAddress(ibclient, pool, addr, retained.Hostname, logger); err != nil {
+		r.recorder.Eventf(pool, corev1.EventTypeWarning, QuarantineFailedEventReason, "Could not quarantine retained address %s of hostname %s: %v", addr, retained.Hostname, err)
+		return err
+	}
+	return nil
+}
+
+// retainedAddress returns the entry recorded in the pool status when the address of a claim is retained.
+func retainedAddress(hostname string, addr netip.Addr, subnet netip.Prefix, ref string, expiration time.Time) v1alpha1.RetainedAddress {
+	return v1alpha1.RetainedAddress{
+		Hostname:       hostname,
+		Address:        addr.String(),
+		Subnet:         subnet.String(),
+		HostRecordRef:  ref,
+		ExpirationTime: metav1.NewTime(expiration),
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox/ibmock"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("releaseRetainedAddress", func() {
+	const ref = "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default"
+
+	var (
+		ibclient   *ibmock.MockClient
+		reconciler *genericPoolReconciler
+		pool       *v1alpha1.InfobloxIPPool
+		retained   v1alpha1.RetainedAddress
+		addr       netip.Addr
+	)
+
+	BeforeEach(func() {
+		ibclient = ibmock.NewMockClient(mockCtrl)
+		ibclient.EXPECT().GetHostConfig().Return(&infoblox.HostConfig{DefaultNetworkView: "default"}).AnyTimes()
+		reconciler = &genericPoolReconciler{recorder: record.NewFakeRecorder(10)}
+		pool = &v1alpha1.InfobloxIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pool", Namespace: "default"},
+			Spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets:    []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+				Quarantine: v1alpha1.Quarantine{Period: metav1.Duration{Duration: 10 * time.Minute}},
+			},
+		}
+		addr = netip.MustParseAddr("10.0.0.5")
+		retained = retainedAddress("test-claim", addr, netip.MustParsePrefix("10.0.0.0/24"), ref, time.Now())
+	})
+
+	It("should quarantine an expired address after releasing it", func() {
+		gomock.InOrder(
+			ibclient.EXPECT().ReleaseAddressByRef(ref, addr, gomock.Any()).Return(nil),
+			ibclient.EXPECT().QuarantineAddress("default", addr, "test-claim", "default/test-pool", gomock.Any(), gomock.Any()).Return(nil),
+		)
+
+		Expect(reconciler.releaseRetainedAddress(ibclient, pool, retained, klog.TODO())).To(Succeed())
+	})
+
+	It("should retry the quarantine of an address that has been released already", func() {
+		ibclient.EXPECT().ReleaseAddressByRef(ref, addr, gomock.Any()).Return(infoblox.ErrNotFound)
+		ibclient.EXPECT().QuarantineAddress("default", addr, "test-claim", "default/test-pool", gomock.Any(), gomock.Any()).Return(nil)
+
+		Expect(reconciler.releaseRetainedAddress(ibclient, pool, retained, klog.TODO())).To(Succeed())
+	})
+
+	It("should keep an address that could not be quarantined", func() {
+		ibclient.EXPECT().ReleaseAddressByRef(ref, addr, gomock.Any()).Return(nil)
+		ibclient.EXPECT().QuarantineAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.ErrValidation)
+
+		Expect(reconciler.releaseRetainedAddress(ibclient, pool, retained, klog.TODO())).To(MatchError(infoblox.ErrValidation))
+	})
+
+	It("should not quarantine an address that has been allocated again", func() {
+		ibclient.EXPECT().ReleaseAddressByRef(ref, addr, gomock.Any()).Return(infoblox.ErrNotFound)
+		ibclient.EXPECT().QuarantineAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(infoblox.ErrConflict)
+
+		Expect(reconciler.releaseRetainedAddress(ibclient, pool, retained, klog.TODO())).To(Succeed())
+	})
+
+	It("should not quarantine the addresses of a deleted pool", func() {
+		pool.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		ibclient.EXPECT().QuarantineAddress(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+		ibclient.EXPECT().ReleaseAddressByRef(ref, addr, gomock.Any()).Return(nil)
+
+		Expect(reconciler.releaseRetainedAddress(ibclient, pool, retained, klog.TODO())).To(Succeed())
+	})
+})
+
+var _ = Describe("retained addresses annotation", func() {
+	var (
+		c    client.Client
+		pool *v1alpha1.InfobloxIPPool
+	)
+
+	BeforeEach(func() {
+		pool = &v1alpha1.InfobloxIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pool", Namespace: "default"},
+			Spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets: []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+			},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pool).WithStatusSubresource(pool).Build()
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(pool), pool)).To(Succeed())
+	})
+
+	It("should restore the retained addresses of a pool moved by clusterctl move", func() {
+		entry := retainedAddress("test-claim", netip.MustParseAddr("10.0.0.5"), netip.MustParsePrefix("10.0.0.0/24"),
+			"record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default", time.Now().Add(time.Hour).Truncate(time.Second))
+		Expect(patchRetainedAddresses(context.Background(), c, pool, func(retained []v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+			return append(retained, entry)
+		})).To(Succeed())
+		Expect(pool.Annotations).To(HaveKey(retainedAddressesAnnotation))
+
+		// clusterctl move copies the annotations of the pool, but not its status.
+		moved := &v1alpha1.InfobloxIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: pool.Name, Namespace: pool.Namespace, Annotations: pool.Annotations},
+			Spec:       pool.Spec,
+		}
+		target := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(moved).WithStatusSubresource(moved).Build()
+		Expect(target.Get(context.Background(), client.ObjectKeyFromObject(moved), moved)).To(Succeed())
+		Expect(moved.Status.RetainedAddresses).To(BeEmpty())
+
+		Expect(restoreRetainedAddresses(context.Background(), target, moved)).To(Succeed())
+		Expect(target.Get(context.Background(), client.ObjectKeyFromObject(moved), moved)).To(Succeed())
+		Expect(moved.Status.RetainedAddresses).To(ConsistOf(SatisfyAll(
+			HaveField("Hostname", "test-claim"),
+			HaveField("Address", "10.0.0.5"),
+			HaveField("HostRecordRef", entry.HostRecordRef),
+			HaveField("ExpirationTime.Time", BeTemporally("==", entry.ExpirationTime.Time)),
+		)))
+	})
+
+	It("should remove the annotation once no addresses are retained", func() {
+		entry := retainedAddress("test-claim", netip.MustParseAddr("10.0.0.5"), netip.MustParsePrefix("10.0.0.0/24"),
+			"record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:test-claim/default", time.Now().Add(time.Hour))
+		Expect(patchRetainedAddresses(context.Background(), c, pool, func(retained []v1alpha1.RetainedAddress) []v1alpha1.RetainedAddress {
+			return append(retained, entry)
+		})).To(Succeed())
+		Expect(patchRetainedAddresses(context.Background(), c, pool, withoutRetainedAddress("test-claim"))).To(Succeed())
+
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(pool), pool)).To(Succeed())
+		Expect(pool.Annotations).NotTo(HaveKey(retainedAddressesAnnotation))
+		Expect(pool.Status.RetainedAddresses).To(BeEmpty())
+	})
+})
@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
@@ -78,7 +79,18 @@ var _ = BeforeSuite(func() {
 	ctx = logf.IntoContext(ctx, logf.Log)
 
 	mockInfobloxClient = ibmock.NewMockClient(mockCtrl)
-	mockNewInfobloxClientFunc = func(infoblox.Config) (infoblox.Client, error) {
+	mockInfobloxClient.EXPECT().GetSchema(gomock.Any()).Return(infoblox.Schema{
+		SupportedVersions: []infoblox.WAPIVersion{{Major: 2, Minor: 5}, {Major: 2, Minor: 12}},
+		SupportedObjects:  []string{"extensibleattributedef", "ipv6network", "ipv6networkcontainer"},
+	}, nil).AnyTimes()
+	mockInfobloxClient.EXPECT().GetGridInfo().Return(infoblox.GridInfo{Name: "Infoblox", WAPIVersion: "2.12", Endpoint: "localhost:443"}, nil).AnyTimes()
+	// Clients for the user "invalid" are rejected by the grid.
+	unauthorizedInfobloxClient := ibmock.NewMockClient(mockCtrl)
+	unauthorizedInfobloxClient.EXPECT().GetSchema(gomock.Any()).Return(infoblox.Schema{}, fmt.Errorf("%w: 401 Unauthorized", infoblox.ErrAuthFailed)).AnyTimes()
+	mockNewInfobloxClientFunc = func(config infoblox.Config) (infoblox.Client, error) {
+		if config.AuthConfig.Username == "invalid" {
+			return unauthorizedInfobloxClient, nil
+		}
 		return mockInfobloxClient, nil
 	}
 
@@ -123,11 +135,16 @@ var _ = BeforeSuite(func() {
 
 	Expect(index.SetupIndexes(ctx, mgr)).To(Succeed())
 
+	credentialSources := NewCredentialSources()
+	rateLimiters := NewRateLimiters()
 	Expect(
 		(&InfobloxInstanceReconciler{
 			Client:                mgr.GetClient(),
 			Scheme:                mgr.GetScheme(),
 			NewInfobloxClientFunc: mockNewInfobloxClientFunc,
+			Recorder:              mgr.GetEventRecorderFor("infobloxinstance-controller"),
+			CredentialSources:     credentialSources,
+			RateLimiters:          rateLimiters,
 		}).SetupWithManager(ctx, mgr),
 	).To(Succeed())
 
@@ -137,6 +154,9 @@ var _ = BeforeSuite(func() {
 			Scheme: mgr.GetScheme(),
 			Adapter: &InfobloxProviderAdapter{
 				NewInfobloxClientFunc: mockNewInfobloxClientFunc,
+				Recorder:              mgr.GetEventRecorderFor("ipaddressclaim-controller"),
+				CredentialSources:     credentialSources,
+				RateLimiters:          rateLimiters,
 			},
 		}).SetupWithManager(ctx, mgr),
 	).To(Succeed())
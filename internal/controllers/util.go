@@ -1,41 +1,137 @@
 package controllers
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/poolutil"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
-	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func getInfobloxClientForInstance(ctx context.Context, client client.Reader, name, secretNamespace string, newClientFn func(infoblox.Config) (infoblox.Client, error)) (infoblox.Client, error) {
+func getInfobloxClientForInstance(ctx context.Context, client client.Reader, name, secretNamespace string, sources *CredentialSources, limiters *RateLimiters, newClientFn func(infoblox.Config) (infoblox.Client, error)) (infoblox.Client, error) {
 	instance := &v1alpha1.InfobloxInstance{}
 	if err := client.Get(ctx, types.NamespacedName{Name: name}, instance); err != nil {
 		return nil, fmt.Errorf("failed to fetch instance: %w", err)
 	}
 
-	secret := &corev1.Secret{}
-	if err := client.Get(ctx, types.NamespacedName{Name: instance.Spec.CredentialsSecretRef.Name, Namespace: secretNamespace}, secret); err != nil {
-		return nil, fmt.Errorf("failed to fetch secret: %w", err)
-	}
-
-	ac, err := infoblox.AuthConfigFromSecretData(secret.Data)
+	ac, err := authConfigForInstance(ctx, client, instance, secretNamespace, sources)
 	if err != nil {
-		return nil, fmt.Errorf("credentials secret is invalid: %w", err)
+		return nil, err
 	}
 	config := infoblox.Config{
-		HostConfig: infoblox.HostConfig{
-			Host:                   instance.Spec.Host + ":" + instance.Spec.Port,
-			Version:                instance.Spec.WAPIVersion,
-			DisableTLSVerification: instance.Spec.DisableTLSVerification,
-			DefaultNetworkView:     instance.Spec.DefaultNetworkView,
-			DefaultDNSView:         instance.Spec.DefaultDNSView,
-		},
+		HostConfig: hostConfigForInstance(instance, limiters),
 		AuthConfig: ac,
 	}
+	if instance.Spec.CABundleRef.Name != "" {
+		config.CustomCA, err = loadCABundle(ctx, client, instance.Spec.CABundleRef, secretNamespace)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return newClientFn(config)
 }
+
+// withAudit returns a function that creates Infoblox clients which write their changes to the audit log of the given context.
+func withAudit(newClientFn func(infoblox.Config) (infoblox.Client, error), audit infoblox.AuditContext) func(infoblox.Config) (infoblox.Client, error) {
+	return func(config infoblox.Config) (infoblox.Client, error) {
+		config.Audit = audit
+		return newClientFn(config)
+	}
+}
+
+// hostConfigForInstance returns the configuration to connect to an InfobloxInstance.
+// If no WAPI version is configured, the version negotiated by the InfobloxInstanceReconciler is used.
+// Requests are sent to the endpoint that answered the last health check first.
+func hostConfigForInstance(instance *v1alpha1.InfobloxInstance, limiters *RateLimiters) infoblox.HostConfig {
+	version := cmp.Or(instance.Spec.WAPIVersion, instance.Status.WAPIVersion, infoblox.MinimumWAPIVersion.String())
+	var features []string
+	if version == instance.Status.WAPIVersion {
+		features = instance.Status.Features
+	}
+	var failover []infoblox.Endpoint
+	for _, e := range instance.Spec.FailoverEndpoints {
+		failover = append(failover, infoblox.Endpoint{Host: e.Host, Port: e.Port})
+	}
+	return infoblox.HostConfig{
+		Host:                   instance.Spec.Host,
+		Port:                   instance.Spec.Port,
+		FailoverEndpoints:      failover,
+		PreferredEndpoint:      instance.Status.ActiveEndpoint,
+		PathPrefix:             instance.Spec.PathPrefix,
+		Version:                version,
+		Features:               features,
+		DisableTLSVerification: instance.Spec.DisableTLSVerification,
+		CustomCAPath:           instance.Spec.CustomCAPath,
+		ProxyURL:               instance.Spec.Proxy.URL,
+		NoProxy:                instance.Spec.Proxy.NoProxy,
+		DefaultNetworkView:     instance.Spec.DefaultNetworkView,
+		DefaultDNSView:         instance.Spec.DefaultDNSView,
+		RateLimiter:            limiters.get(instance),
+		RequestTimeout:         instance.Spec.RequestTimeout.Duration,
+		Retry:                  retryConfigFor(instance),
+	}
+}
+
+// instanceAllowsNamespace checks whether the InfobloxInstance with the given name may be used from the given namespace.
+// A missing instance is not reported here, since creating a client for it fails anyway.
+func instanceAllowsNamespace(ctx context.Context, client client.Reader, name, namespace string) (bool, error) {
+	instance := &v1alpha1.InfobloxInstance{}
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, instance); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to fetch instance: %w", err)
+	}
+	return poolutil.NamespaceAllowed(ctx, client, instance.Spec.AllowedNamespaces, namespace)
+}
+
+// instanceUnreachableMessage returns the message of the Ready condition of an InfobloxInstance if its last health check failed.
+// An empty message is returned if the instance is reachable or doesn't exist, since creating a client for it fails anyway.
+func instanceUnreachableMessage(ctx context.Context, client client.Reader, name string) (string, error) {
+	instance := &v1alpha1.InfobloxInstance{}
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, instance); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to fetch instance: %w", err)
+	}
+	ready := conditions.Get(instance, clusterv1.ReadyCondition)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != v1alpha1.InstanceUnreachableReason {
+		return "", nil
+	}
+	return ready.Message, nil
+}
+
+// infobloxErrorReason returns the condition reason for an error returned by the Infoblox client.
+// Errors without a more specific reason are reported with the fallback reason.
+func infobloxErrorReason(err error, fallback string) string {
+	switch {
+	case errors.Is(err, infoblox.ErrAuthFailed):
+		return v1alpha1.AuthenticationFailedReason
+	case errors.Is(err, infoblox.ErrTransient):
+		return v1alpha1.InstanceUnreachableReason
+	case errors.Is(err, infoblox.ErrDryRun):
+		return v1alpha1.DryRunReason
+	default:
+		return fallback
+	}
+}
+
+// requeueInfobloxError returns the error if it is transient, so the object is retried with backoff.
+// Other errors don't resolve without changes to the object, its instance or the grid, so they are only reported in the conditions.
+func requeueInfobloxError(err error) error {
+	if errors.Is(err, infoblox.ErrTransient) {
+		return err
+	}
+	return nil
+}
@@ -19,9 +19,14 @@ package poolutil
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/index"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -66,3 +71,21 @@ func ListClaimsReferencingPool(ctx context.Context, c client.Client, namespace s
 	}
 	return addr, err
 }
+
+// NamespaceAllowed checks whether the labels of the given namespace match an allowed namespaces selector.
+// A nil selector allows all namespaces.
+func NamespaceAllowed(ctx context.Context, c client.Reader, selector *metav1.LabelSelector, namespace string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return false, fmt.Errorf("failed to fetch namespace: %w", err)
+	}
+	return sel.Matches(labels.Set(ns.Labels)), nil
+}
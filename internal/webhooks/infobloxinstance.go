@@ -0,0 +1,429 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const defaultInstancePort = "443"
+
+func (webhook *InfobloxInstance) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.InfobloxInstance{}).
+		WithDefaulter(webhook).
+		WithValidator(webhook).
+		Complete()
+}
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-ipam-cluster-x-k8s-io-v1alpha1-infobloxinstance,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxinstances,versions=v1alpha1,name=validation.infobloxinstance.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-cluster-x-k8s-io-v1alpha1-infobloxinstance,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxinstances,versions=v1alpha1,name=default.infobloxinstance.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+// InfobloxInstance implements a validating and defaulting webhook for InfobloxInstance.
+type InfobloxInstance struct {
+	Client client.Client
+}
+
+var _ webhook.CustomDefaulter = &InfobloxInstance{}
+var _ webhook.CustomValidator = &InfobloxInstance{}
+
+// Default satisfies the defaulting webhook interface.
+func (webhook *InfobloxInstance) Default(_ context.Context, obj runtime.Object) error {
+	instance, ok := obj.(*v1alpha1.InfobloxInstance)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxInstance but got a %T", obj))
+	}
+	if instance.Spec.Port == "" {
+		instance.Spec.Port = defaultInstancePort
+	}
+	return nil
+}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *InfobloxInstance) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	instance, ok := obj.(*v1alpha1.InfobloxInstance)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxInstance but got a %T", obj))
+	}
+	if _, err := webhook.validate(nil, instance); err != nil {
+		return nil, err
+	}
+	return nil, webhook.validateCredentialsAccess(ctx, instance)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *InfobloxInstance) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldInstance, ok := oldObj.(*v1alpha1.InfobloxInstance)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxInstance but got a %T", oldObj))
+	}
+	instance, ok := newObj.(*v1alpha1.InfobloxInstance)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxInstance but got a %T", newObj))
+	}
+	warnings, err := webhook.validate(oldInstance, instance)
+	if err != nil {
+		return warnings, err
+	}
+	// Access is reviewed again if any reference changes, or the credentials source switches back to the secret.
+	if oldInstance.Spec.CredentialsSecretRef == instance.Spec.CredentialsSecretRef &&
+		oldInstance.Spec.CredentialsSource.Type == instance.Spec.CredentialsSource.Type &&
+		oldInstance.Spec.CABundleRef == instance.Spec.CABundleRef {
+		return warnings, nil
+	}
+	return warnings, webhook.validateCredentialsAccess(ctx, instance)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *InfobloxInstance) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	instance, ok := obj.(*v1alpha1.InfobloxInstance)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxInstance but got a %T", obj))
+	}
+
+	if skipValidateDelete(instance) {
+		return nil, nil
+	}
+
+	pools, err := webhook.listPoolsReferencingInstance(ctx, instance.Name)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+
+	if len(pools) > 0 {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("Instance is referenced by pools %s. Cannot delete Instance until all pools referencing it have been removed.", strings.Join(pools, ", ")))
+	}
+
+	return nil, nil
+}
+
+// listPoolsReferencingInstance returns the names of all InfobloxIPPools and GlobalInfobloxIPPools that reference the instance.
+func (webhook *InfobloxInstance) listPoolsReferencingInstance(ctx context.Context, name string) ([]string, error) {
+	var names []string
+
+	pools := &v1alpha1.InfobloxIPPoolList{}
+	if err := webhook.Client.List(ctx, pools); err != nil {
+		return nil, fmt.Errorf("failed to list pools: %w", err)
+	}
+	for _, pool := range pools.Items {
+		if pool.Spec.InstanceRef.Name == name {
+			names = append(names, pool.Namespace+"/"+pool.Name)
+		}
+	}
+
+	globalPools := &v1alpha1.GlobalInfobloxIPPoolList{}
+	if err := webhook.Client.List(ctx, globalPools); err != nil {
+		return nil, fmt.Errorf("failed to list global pools: %w", err)
+	}
+	for _, pool := range globalPools.Items {
+		if pool.Spec.InstanceRef.Name == name {
+			names = append(names, pool.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// validate validates an instance. oldInstance is nil on create.
+// An invalid host that is unchanged by an update only results in a warning, so instances created before the host was
+// validated, e.g. with a host:port value, can still be updated.
+func (webhook *InfobloxInstance) validate(oldInstance, instance *v1alpha1.InfobloxInstance) (admission.Warnings, error) {
+	var allErrs field.ErrorList
+	var warnings admission.Warnings
+	spec := instance.Spec
+
+	if err := validateHost(spec.Host); err != "" {
+		if oldInstance != nil && oldInstance.Spec.Host == spec.Host {
+			warnings = append(warnings, fmt.Sprintf("spec.host: %s, it will be rejected once host is changed", err))
+		} else {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "host"), spec.Host, err))
+		}
+	}
+
+	if port, err := strconv.Atoi(spec.Port); err != nil || port < 1 || port > 65535 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "port"), spec.Port, "port must be a number between 1 and 65535"))
+	}
+
+	allErrs = append(allErrs, validateFailoverEndpoints(spec)...)
+
+	if spec.PathPrefix != "" {
+		if u, err := url.Parse(spec.PathPrefix); err != nil || !strings.HasPrefix(spec.PathPrefix, "/") || u.Path != spec.PathPrefix {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "pathPrefix"), spec.PathPrefix, "pathPrefix must be an absolute URL path"))
+		}
+	}
+
+	if spec.Proxy.URL != "" {
+		if u, err := url.Parse(spec.Proxy.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "proxy", "url"), spec.Proxy.URL, "proxy url must be an http or https URL"))
+		}
+	} else if len(spec.Proxy.NoProxy) > 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "proxy", "noProxy"), spec.Proxy.NoProxy, "noProxy requires a proxy url"))
+	}
+
+	// An empty WAPI version is negotiated with the grid.
+	if spec.WAPIVersion != "" {
+		if version, err := infoblox.ParseWAPIVersion(spec.WAPIVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "wapiVersion"), spec.WAPIVersion, err.Error()))
+		} else if version.Compare(infoblox.MinimumWAPIVersion) < 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "wapiVersion"), spec.WAPIVersion,
+				fmt.Sprintf("WAPI version must be at least %s", infoblox.MinimumWAPIVersion)))
+		}
+	}
+
+	allErrs = append(allErrs, validateCredentialsSource(spec)...)
+	allErrs = append(allErrs, validateRequestSettings(spec)...)
+
+	if spec.DisableTLSVerification && spec.CustomCAPath != "" {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "customCAPath"), spec.CustomCAPath, "customCAPath and disableTLSVerification are mutually exclusive"))
+	}
+
+	if spec.CABundleRef.Name != "" {
+		if spec.CustomCAPath != "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "caBundleRef"), spec.CABundleRef.Name, "caBundleRef and customCAPath are mutually exclusive"))
+		}
+		if spec.DisableTLSVerification {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "caBundleRef"), spec.CABundleRef.Name, "caBundleRef and disableTLSVerification are mutually exclusive"))
+		}
+	}
+
+	if spec.AllowedNamespaces != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.AllowedNamespaces); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "allowedNamespaces"), spec.AllowedNamespaces, err.Error()))
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return warnings, apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("InfobloxInstance").GroupKind(), instance.Name, allErrs)
+	}
+	return warnings, nil
+}
+
+// validateCredentialsAccess makes sure that the requesting user may read the secrets referenced by an instance in a namespace
+// other than the provider's, i.e. the credentials secret and a CA bundle stored in a secret.
+// Otherwise the permissions of the provider could be used to read secrets the user has no access to.
+func (webhook *InfobloxInstance) validateCredentialsAccess(ctx context.Context, instance *v1alpha1.InfobloxInstance) error {
+	type secretReference struct {
+		path            *field.Path
+		name, namespace string
+	}
+	var refs []secretReference
+	if ref := instance.Spec.CredentialsSecretRef; ref.Namespace != "" && instance.Spec.CredentialsSource.UsesSecret() {
+		refs = append(refs, secretReference{path: field.NewPath("spec", "credentialsSecretRef", "namespace"), name: ref.Name, namespace: ref.Namespace})
+	}
+	if ref := instance.Spec.CABundleRef; ref.Namespace != "" && ref.Kind == v1alpha1.CABundleKindSecret {
+		refs = append(refs, secretReference{path: field.NewPath("spec", "caBundleRef", "namespace"), name: ref.Name, namespace: ref.Namespace})
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range req.UserInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	var allErrs field.ErrorList
+	for _, ref := range refs {
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   req.UserInfo.Username,
+				UID:    req.UserInfo.UID,
+				Groups: req.UserInfo.Groups,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: ref.namespace,
+					Verb:      "get",
+					Resource:  "secrets",
+					Name:      ref.name,
+				},
+			},
+		}
+		if err := webhook.Client.Create(ctx, review); err != nil {
+			return apierrors.NewInternalError(fmt.Errorf("failed to review access to secret %s/%s: %w", ref.namespace, ref.name, err))
+		}
+		if !review.Status.Allowed {
+			allErrs = append(allErrs, field.Forbidden(ref.path,
+				fmt.Sprintf("user %q is not allowed to get secret %q in namespace %q", req.UserInfo.Username, ref.name, ref.namespace)))
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("InfobloxInstance").GroupKind(), instance.Name, allErrs)
+	}
+	return nil
+}
+
+// validateCredentialsSource makes sure exactly the settings of the selected credentials source are set.
+func validateCredentialsSource(spec v1alpha1.InfobloxInstanceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	source := spec.CredentialsSource
+	sourcePath := field.NewPath("spec", "credentialsSource")
+
+	if source.UsesSecret() {
+		if spec.CredentialsSecretRef.Name == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec", "credentialsSecretRef", "name"), "credentialsSecretRef is required for the Secret credentials source"))
+		}
+	} else if spec.CredentialsSecretRef != (v1alpha1.CredentialsReferece{}) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "credentialsSecretRef"), "credentialsSecretRef can only be used with the Secret credentials source"))
+	}
+
+	if source.Type == v1alpha1.CredentialsSourceTypeFile {
+		if !filepath.IsAbs(source.File.Path) {
+			allErrs = append(allErrs, field.Invalid(sourcePath.Child("file", "path"), source.File.Path, "path must be an absolute path"))
+		}
+	} else if source.File != (v1alpha1.FileCredentialsSource{}) {
+		allErrs = append(allErrs, field.Forbidden(sourcePath.Child("file"), "file can only be used with the File credentials source"))
+	}
+
+	if source.Type == v1alpha1.CredentialsSourceTypeTokenExchange {
+		if msg := validateTokenExchangeURL(source.TokenExchange.URL); msg != "" {
+			allErrs = append(allErrs, field.Invalid(sourcePath.Child("tokenExchange", "url"), source.TokenExchange.URL, msg))
+		}
+	} else if source.TokenExchange != (v1alpha1.TokenExchangeCredentialsSource{}) {
+		allErrs = append(allErrs, field.Forbidden(sourcePath.Child("tokenExchange"), "tokenExchange can only be used with the TokenExchange credentials source"))
+	}
+
+	return allErrs
+}
+
+// validateTokenExchangeURL returns an error message if the URL of a token exchange endpoint is invalid.
+// The endpoint must be a local agent, i.e. on a loopback address or a Service in the cluster, so the controller doesn't
+// fetch credentials from arbitrary hosts. Credentials must not be sent unencrypted over the network, so plain HTTP is
+// only allowed for loopback addresses.
+func validateTokenExchangeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "url must be an http or https URL"
+	}
+	host := u.Hostname()
+	addr, err := netip.ParseAddr(host)
+	loopback := (err == nil && addr.IsLoopback()) || host == "localhost"
+	switch {
+	case !loopback && !isServiceHost(host):
+		return "url must point to a loopback address or a Service in the cluster"
+	case u.Scheme == "http" && !loopback:
+		return "url must use https unless the endpoint is on a loopback address"
+	}
+	return ""
+}
+
+// isServiceHost returns whether a host is the DNS name of a Service in the cluster, e.g. vault.vault.svc or vault.vault.svc.cluster.local.
+func isServiceHost(host string) bool {
+	labels := strings.Split(host, ".")
+	return len(labels) >= 3 && labels[2] == "svc"
+}
+
+// validateFailoverEndpoints validates the failover endpoints of an instance. Every endpoint, including the primary one, must be unique.
+func validateFailoverEndpoints(spec v1alpha1.InfobloxInstanceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{net.JoinHostPort(spec.Host, spec.Port): true}
+	for i, e := range spec.FailoverEndpoints {
+		path := field.NewPath("spec", "failoverEndpoints").Index(i)
+		if err := validateHost(e.Host); err != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("host"), e.Host, err))
+		}
+		if e.Port != "" {
+			if port, err := strconv.Atoi(e.Port); err != nil || port < 1 || port > 65535 {
+				allErrs = append(allErrs, field.Invalid(path.Child("port"), e.Port, "port must be a number between 1 and 65535"))
+			}
+		}
+		endpoint := net.JoinHostPort(e.Host, cmp.Or(e.Port, spec.Port))
+		if seen[endpoint] {
+			allErrs = append(allErrs, field.Duplicate(path, endpoint))
+		}
+		seen[endpoint] = true
+	}
+	return allErrs
+}
+
+// validateRequestSettings validates the rate limit, timeout and retry settings of an instance.
+func validateRequestSettings(spec v1alpha1.InfobloxInstanceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.RateLimit.QPS < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "rateLimit", "qps"), spec.RateLimit.QPS, "qps must be positive"))
+	}
+	if spec.RateLimit.Burst < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "rateLimit", "burst"), spec.RateLimit.Burst, "burst must be positive"))
+	} else if spec.RateLimit.Burst > 0 && spec.RateLimit.QPS == 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "rateLimit", "burst"), spec.RateLimit.Burst, "burst requires qps"))
+	}
+	if spec.RequestTimeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "requestTimeout"), spec.RequestTimeout.Duration.String(), "requestTimeout must not be negative"))
+	}
+
+	retry := spec.Retry
+	retryPath := field.NewPath("spec", "retry")
+	if retry.MaxRetries != nil && (*retry.MaxRetries < 0 || *retry.MaxRetries > 10) {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("maxRetries"), *retry.MaxRetries, "maxRetries must be between 0 and 10"))
+	}
+	if retry.InitialBackoff.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("initialBackoff"), retry.InitialBackoff.Duration.String(), "initialBackoff must not be negative"))
+	}
+	if retry.MaxBackoff.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("maxBackoff"), retry.MaxBackoff.Duration.String(), "maxBackoff must not be negative"))
+	} else if retry.MaxBackoff.Duration > 0 && retry.MaxBackoff.Duration < retry.InitialBackoff.Duration {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("maxBackoff"), retry.MaxBackoff.Duration.String(), "maxBackoff must not be less than initialBackoff"))
+	}
+	return allErrs
+}
+
+// validateHost returns an error message if host is neither an IP address nor a DNS name.
+func validateHost(host string) string {
+	if host == "" {
+		return "host is required"
+	}
+	if strings.Contains(host, "://") || strings.Contains(host, "/") {
+		return "host must not contain a scheme or path"
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return ""
+	}
+	if strings.Contains(host, ":") {
+		return "host must not contain a port, use the port field instead"
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return "host must be an IP address or a DNS name: " + strings.Join(errs, ", ")
+	}
+	return ""
+}
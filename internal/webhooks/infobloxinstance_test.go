@@ -0,0 +1,475 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newTestInstance() *v1alpha1.InfobloxInstance {
+	return &v1alpha1.InfobloxInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-instance",
+		},
+		Spec: v1alpha1.InfobloxInstanceSpec{
+			Host:                 "infoblox.example.com",
+			Port:                 "443",
+			WAPIVersion:          "2.12",
+			CredentialsSecretRef: v1alpha1.CredentialsReferece{Name: "infoblox-credentials"},
+		},
+	}
+}
+
+func TestInstanceDefaulting(t *testing.T) {
+	g := NewWithT(t)
+
+	instance := newTestInstance()
+	instance.Spec.Port = ""
+
+	webhook := InfobloxInstance{}
+	g.Expect(webhook.Default(ctx, instance)).To(Succeed())
+	g.Expect(instance.Spec.Port).To(Equal("443"))
+}
+
+func TestValidInstances(t *testing.T) {
+	tests := map[string]func(*v1alpha1.InfobloxInstance){
+		"DNS name":                func(*v1alpha1.InfobloxInstance) {},
+		"IPv4 address":            func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "10.0.0.10" },
+		"IPv6 address":            func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "2001:db8::10" },
+		"WAPI version w/ patch":   func(i *v1alpha1.InfobloxInstance) { i.Spec.WAPIVersion = "2.12.3" },
+		"negotiated WAPI version": func(i *v1alpha1.InfobloxInstance) { i.Spec.WAPIVersion = "" },
+		"custom CA":               func(i *v1alpha1.InfobloxInstance) { i.Spec.CustomCAPath = "/etc/ssl/infoblox.pem" },
+		"path prefix":             func(i *v1alpha1.InfobloxInstance) { i.Spec.PathPrefix = "/infoblox" },
+		"file credentials": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+			i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+				Type: v1alpha1.CredentialsSourceTypeFile,
+				File: v1alpha1.FileCredentialsSource{Path: "/var/run/secrets/infoblox"},
+			}
+		},
+		"token exchange credentials": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+			i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+				Type:          v1alpha1.CredentialsSourceTypeTokenExchange,
+				TokenExchange: v1alpha1.TokenExchangeCredentialsSource{URL: "http://127.0.0.1:8100/v1/secret/data/infoblox"},
+			}
+		},
+		"token exchange with an in-cluster endpoint": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+			i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+				Type:          v1alpha1.CredentialsSourceTypeTokenExchange,
+				TokenExchange: v1alpha1.TokenExchangeCredentialsSource{URL: "https://vault.vault.svc:8200/v1/secret/data/infoblox"},
+			}
+		},
+		"CA bundle": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.CABundleRef = v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindConfigMap, Name: "infoblox-ca"}
+		},
+		"failover endpoints": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.FailoverEndpoints = []v1alpha1.Endpoint{{Host: "infoblox-2.example.com"}, {Host: "10.0.0.11", Port: "8443"}}
+		},
+		"rate limit and retries": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.RateLimit = v1alpha1.RateLimitConfig{QPS: 10, Burst: 20}
+			i.Spec.RequestTimeout = metav1.Duration{Duration: 10 * time.Second}
+			i.Spec.Retry = v1alpha1.RetryConfig{MaxRetries: ptr.To[int32](0), InitialBackoff: metav1.Duration{Duration: time.Second}}
+		},
+		"proxy": func(i *v1alpha1.InfobloxInstance) {
+			i.Spec.Proxy = v1alpha1.ProxyConfig{URL: "http://proxy.example.com:3128", NoProxy: []string{"10.0.0.0/8"}}
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			instance := newTestInstance()
+			mutate(instance)
+
+			webhook := InfobloxInstance{}
+			g.Expect(testCreate(ctx, instance, &webhook)).To(Succeed())
+			g.Expect(testUpdate(ctx, instance, &webhook)).To(Succeed())
+		})
+	}
+}
+
+func TestInvalidInstances(t *testing.T) {
+	tests := []struct {
+		testcase      string
+		mutate        func(*v1alpha1.InfobloxInstance)
+		expectedError string
+	}{
+		{
+			testcase:      "host must be set",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "" },
+			expectedError: "host is required",
+		},
+		{
+			testcase:      "host must not contain a scheme",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "https://infoblox.example.com" },
+			expectedError: "host must not contain a scheme or path",
+		},
+		{
+			testcase:      "host must not contain a port",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "infoblox.example.com:8443" },
+			expectedError: "host must not contain a port",
+		},
+		{
+			testcase:      "host must be a DNS name",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Host = "infoblox_example" },
+			expectedError: "host must be an IP address or a DNS name",
+		},
+		{
+			testcase:      "port must be a number",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Port = "https" },
+			expectedError: "port must be a number between 1 and 65535",
+		},
+		{
+			testcase:      "port must be in range",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Port = "70000" },
+			expectedError: "port must be a number between 1 and 65535",
+		},
+		{
+			testcase:      "path prefix must be absolute",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.PathPrefix = "infoblox" },
+			expectedError: "pathPrefix must be an absolute URL path",
+		},
+		{
+			testcase:      "path prefix must not contain a query",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.PathPrefix = "/infoblox?foo=bar" },
+			expectedError: "pathPrefix must be an absolute URL path",
+		},
+		{
+			testcase:      "proxy url must be an http URL",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Proxy.URL = "proxy.example.com:3128" },
+			expectedError: "proxy url must be an http or https URL",
+		},
+		{
+			testcase:      "noProxy requires a proxy",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.Proxy.NoProxy = []string{"10.0.0.0/8"} },
+			expectedError: "noProxy requires a proxy url",
+		},
+		{
+			testcase:      "WAPI version must not have a leading v",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.WAPIVersion = "v2.12" },
+			expectedError: "invalid WAPI version",
+		},
+		{
+			testcase:      "WAPI version must have a minor version",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.WAPIVersion = "2" },
+			expectedError: "invalid WAPI version",
+		},
+		{
+			testcase:      "WAPI version must be supported",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.WAPIVersion = "1.4" },
+			expectedError: "WAPI version must be at least 2.5",
+		},
+		{
+			testcase: "TLS options are mutually exclusive",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.DisableTLSVerification = true
+				i.Spec.CustomCAPath = "/etc/ssl/infoblox.pem"
+			},
+			expectedError: "customCAPath and disableTLSVerification are mutually exclusive",
+		},
+		{
+			testcase:      "credentials secret is required by default",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{} },
+			expectedError: "credentialsSecretRef is required for the Secret credentials source",
+		},
+		{
+			testcase: "credentials secret can only be used with the Secret credentials source",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+					Type: v1alpha1.CredentialsSourceTypeFile,
+					File: v1alpha1.FileCredentialsSource{Path: "/var/run/secrets/infoblox"},
+				}
+			},
+			expectedError: "credentialsSecretRef can only be used with the Secret credentials source",
+		},
+		{
+			testcase: "file credentials require an absolute path",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+				i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+					Type: v1alpha1.CredentialsSourceTypeFile,
+					File: v1alpha1.FileCredentialsSource{Path: "secrets/infoblox"},
+				}
+			},
+			expectedError: "path must be an absolute path",
+		},
+		{
+			testcase: "token exchange must not send credentials unencrypted over the network",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+				i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+					Type:          v1alpha1.CredentialsSourceTypeTokenExchange,
+					TokenExchange: v1alpha1.TokenExchangeCredentialsSource{URL: "http://vault.vault.svc/v1/secret/data/infoblox"},
+				}
+			},
+			expectedError: "url must use https unless the endpoint is on a loopback address",
+		},
+		{
+			testcase: "token exchange must not fetch credentials from remote hosts",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CredentialsSecretRef = v1alpha1.CredentialsReferece{}
+				i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+					Type:          v1alpha1.CredentialsSourceTypeTokenExchange,
+					TokenExchange: v1alpha1.TokenExchangeCredentialsSource{URL: "https://vault.example.com/v1/secret/data/infoblox"},
+				}
+			},
+			expectedError: "url must point to a loopback address or a Service in the cluster",
+		},
+		{
+			testcase: "settings of other credentials sources are forbidden",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CredentialsSource = v1alpha1.CredentialsSource{
+					File: v1alpha1.FileCredentialsSource{Path: "/var/run/secrets/infoblox"},
+				}
+			},
+			expectedError: "file can only be used with the File credentials source",
+		},
+		{
+			testcase: "CA bundle and CA path are mutually exclusive",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CABundleRef = v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindSecret, Name: "infoblox-ca"}
+				i.Spec.CustomCAPath = "/etc/ssl/infoblox.pem"
+			},
+			expectedError: "caBundleRef and customCAPath are mutually exclusive",
+		},
+		{
+			testcase: "CA bundle and disabled TLS verification are mutually exclusive",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.CABundleRef = v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindSecret, Name: "infoblox-ca"}
+				i.Spec.DisableTLSVerification = true
+			},
+			expectedError: "caBundleRef and disableTLSVerification are mutually exclusive",
+		},
+		{
+			testcase: "failover endpoints must be valid",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.FailoverEndpoints = []v1alpha1.Endpoint{{Host: "infoblox-2.example.com", Port: "0"}}
+			},
+			expectedError: "spec.failoverEndpoints[0].port",
+		},
+		{
+			testcase: "failover endpoints must be unique",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.FailoverEndpoints = []v1alpha1.Endpoint{{Host: i.Spec.Host, Port: i.Spec.Port}}
+			},
+			expectedError: "Duplicate value",
+		},
+		{
+			testcase:      "burst requires qps",
+			mutate:        func(i *v1alpha1.InfobloxInstance) { i.Spec.RateLimit.Burst = 10 },
+			expectedError: "burst requires qps",
+		},
+		{
+			testcase: "max backoff must not be less than initial backoff",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.Retry = v1alpha1.RetryConfig{
+					InitialBackoff: metav1.Duration{Duration: 10 * time.Second},
+					MaxBackoff:     metav1.Duration{Duration: time.Second},
+				}
+			},
+			expectedError: "maxBackoff must not be less than initialBackoff",
+		},
+		{
+			testcase: "allowedNamespaces must be a valid selector",
+			mutate: func(i *v1alpha1.InfobloxInstance) {
+				i.Spec.AllowedNamespaces = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tenant", Operator: "Unknown"}},
+				}
+			},
+			expectedError: "allowedNamespaces",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testcase, func(t *testing.T) {
+			g := NewWithT(t)
+			instance := newTestInstance()
+			tt.mutate(instance)
+
+			webhook := InfobloxInstance{}
+			g.Expect(testCreate(ctx, instance, &webhook)).To(MatchError(ContainSubstring(tt.expectedError)))
+			_, err := webhook.ValidateUpdate(ctx, newTestInstance(), instance)
+			g.Expect(err).To(MatchError(ContainSubstring(tt.expectedError)))
+		})
+	}
+}
+
+func TestInstanceWithLegacyHostCanBeUpdated(t *testing.T) {
+	g := NewWithT(t)
+
+	oldInstance := newTestInstance()
+	oldInstance.Spec.Host = "infoblox.example.com:8443"
+	instance := oldInstance.DeepCopy()
+	instance.Spec.WAPIVersion = "2.13"
+
+	webhook := InfobloxInstance{}
+	warnings, err := webhook.ValidateUpdate(ctx, oldInstance, instance)
+	g.Expect(err).NotTo(HaveOccurred(), "an unchanged host should not prevent updates")
+	g.Expect(warnings).To(ConsistOf(ContainSubstring("host must not contain a port")))
+
+	instance.Spec.Host = "infoblox-2.example.com:8443"
+	_, err = webhook.ValidateUpdate(ctx, oldInstance, instance)
+	g.Expect(err).To(MatchError(ContainSubstring("host must not contain a port")), "a changed host should be validated")
+
+	instance.Spec.Host = "infoblox.example.com"
+	g.Expect(webhook.ValidateUpdate(ctx, oldInstance, instance)).To(BeEmpty())
+}
+
+func TestInstanceDeletionWithReferencingPools(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	instance := newTestInstance()
+	pool := &v1alpha1.InfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pool",
+			Namespace: "test-namespace",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: instance.Name},
+		},
+	}
+	globalPool := &v1alpha1.GlobalInfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-global-pool",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: instance.Name},
+		},
+	}
+	unrelatedPool := &v1alpha1.InfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unrelated-pool",
+			Namespace: "test-namespace",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: "other-instance"},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(pool, globalPool, unrelatedPool).
+		Build()
+
+	webhook := InfobloxInstance{
+		Client: fakeClient,
+	}
+
+	_, err := webhook.ValidateDelete(ctx, instance)
+	g.Expect(err).To(MatchError(ContainSubstring("test-namespace/my-pool, my-global-pool")), "should not allow deletion while pools reference the instance")
+
+	instance.Annotations = map[string]string{SkipValidateDeleteWebhookAnnotation: ""}
+	_, err = webhook.ValidateDelete(ctx, instance)
+	g.Expect(err).ToNot(HaveOccurred(), "should allow deletion when the skip annotation is set")
+
+	instance.Annotations = map[string]string{clusterctlv1.DeleteForMoveAnnotation: ""}
+	_, err = webhook.ValidateDelete(ctx, instance)
+	g.Expect(err).ToNot(HaveOccurred(), "should allow deletion by clusterctl move")
+
+	instance.Annotations = nil
+	g.Expect(fakeClient.Delete(ctx, pool)).To(Succeed())
+	g.Expect(fakeClient.Delete(ctx, globalPool)).To(Succeed())
+
+	_, err = webhook.ValidateDelete(ctx, instance)
+	g.Expect(err).ToNot(HaveOccurred(), "should allow deletion when no pools reference the instance")
+}
+
+func TestInstanceCredentialsInOtherNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(authorizationv1.AddToScheme(scheme)).To(Succeed())
+
+	var reviewed *authorizationv1.SubjectAccessReview
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+				reviewed = obj.(*authorizationv1.SubjectAccessReview)
+				reviewed.Status.Allowed = reviewed.Spec.User == "admin"
+				return nil
+			},
+		}).
+		Build()
+	webhook := InfobloxInstance{Client: fakeClient}
+
+	requestBy := func(user string) context.Context {
+		return admission.NewContextWithRequest(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			UserInfo: authenticationv1.UserInfo{Username: user},
+		}})
+	}
+
+	instance := newTestInstance()
+	g.Expect(testCreate(requestBy("tenant"), instance, &webhook)).To(Succeed(), "should not review access to secrets in the provider namespace")
+	g.Expect(reviewed).To(BeNil())
+
+	instance.Spec.CredentialsSecretRef.Namespace = "vault-secrets"
+	g.Expect(testCreate(requestBy("admin"), instance, &webhook)).To(Succeed())
+	g.Expect(reviewed.Spec.ResourceAttributes).To(Equal(&authorizationv1.ResourceAttributes{
+		Namespace: "vault-secrets",
+		Verb:      "get",
+		Resource:  "secrets",
+		Name:      "infoblox-credentials",
+	}))
+
+	g.Expect(testCreate(requestBy("tenant"), instance, &webhook)).To(MatchError(ContainSubstring(`user "tenant" is not allowed to get secret`)))
+
+	oldInstance := newTestInstance()
+	_, err := webhook.ValidateUpdate(requestBy("tenant"), oldInstance, instance)
+	g.Expect(err).To(MatchError(ContainSubstring(`user "tenant" is not allowed to get secret`)), "should review access when the reference changes")
+
+	_, err = webhook.ValidateUpdate(requestBy("tenant"), instance.DeepCopy(), instance)
+	g.Expect(err).ToNot(HaveOccurred(), "should not review access when the reference is unchanged")
+
+	instance = newTestInstance()
+	instance.Spec.CABundleRef = v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindSecret, Name: "infoblox-ca", Namespace: "vault-secrets"}
+	g.Expect(testCreate(requestBy("admin"), instance, &webhook)).To(Succeed())
+	g.Expect(reviewed.Spec.ResourceAttributes).To(Equal(&authorizationv1.ResourceAttributes{
+		Namespace: "vault-secrets",
+		Verb:      "get",
+		Resource:  "secrets",
+		Name:      "infoblox-ca",
+	}))
+	g.Expect(testCreate(requestBy("tenant"), instance, &webhook)).To(MatchError(ContainSubstring(`user "tenant" is not allowed to get secret "infoblox-ca"`)))
+
+	_, err = webhook.ValidateUpdate(requestBy("tenant"), newTestInstance(), instance)
+	g.Expect(err).To(MatchError(ContainSubstring(`user "tenant" is not allowed to get secret "infoblox-ca"`)), "should review access when the CA bundle reference changes")
+
+	reviewed = nil
+	instance.Spec.CABundleRef.Kind = v1alpha1.CABundleKindConfigMap
+	g.Expect(testCreate(requestBy("tenant"), instance, &webhook)).To(Succeed(), "should not review access to CA bundles in config maps")
+	g.Expect(reviewed).To(BeNil())
+}
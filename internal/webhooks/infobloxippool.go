@@ -25,9 +25,12 @@ import (
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/poolutil"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -36,23 +39,43 @@ import (
 
 const (
 	// SkipValidateDeleteWebhookAnnotation is an annotation that can be applied
-	// to the InClusterIPPool or GlobalInClusterIPPool to skip delete
-	// validation. Necessary for clusterctl move to work as expected.
+	// to the InfobloxIPPool, GlobalInfobloxIPPool or InfobloxInstance to skip
+	// delete validation. Objects deleted by clusterctl move carry the
+	// clusterctl delete-for-move annotation instead, which is accepted as well.
 	SkipValidateDeleteWebhookAnnotation = "ipam.cluster.x-k8s.io/skip-validate-delete-webhook"
 )
 
+// skipValidateDelete returns whether delete validation is skipped for the object,
+// either on request or because clusterctl move deletes it after moving it.
+func skipValidateDelete(obj client.Object) bool {
+	annotations := obj.GetAnnotations()
+	_, skip := annotations[SkipValidateDeleteWebhookAnnotation]
+	_, move := annotations[clusterctlv1.DeleteForMoveAnnotation]
+	return skip || move
+}
+
 func (webhook *InfobloxIPPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
+	err := ctrl.NewWebhookManagedBy(mgr).
 		For(&v1alpha1.InfobloxIPPool{}).
 		WithDefaulter(webhook).
 		WithValidator(webhook).
 		Complete()
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.GlobalInfobloxIPPool{}).
+		WithDefaulter(webhook).
+		WithValidator(webhook).
+		Complete()
 }
 
-// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-ipam-cluster-x-k8s-io-v1alpha1-infobloxippool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxippools,versions=v1alpha2,name=validation.infobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
-// +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-cluster-x-k8s-io-v1alpha1-infobloxippool,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxippools,versions=v1alpha2,name=default.infobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-ipam-cluster-x-k8s-io-v1alpha1-infobloxippool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxippools,versions=v1alpha1,name=validation.infobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-cluster-x-k8s-io-v1alpha1-infobloxippool,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=infobloxippools,versions=v1alpha1,name=default.infobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-ipam-cluster-x-k8s-io-v1alpha1-globalinfobloxippool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=globalinfobloxippools,versions=v1alpha1,name=validation.globalinfobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-cluster-x-k8s-io-v1alpha1-globalinfobloxippool,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=ipam.cluster.x-k8s.io,resources=globalinfobloxippools,versions=v1alpha1,name=default.globalinfobloxippool.ipam.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
 
-// InfobloxIPPool implements a validating and defaulting webhook for InfobloxIPPool.
+// InfobloxIPPool implements a validating and defaulting webhook for InfobloxIPPool and GlobalInfobloxIPPool.
 type InfobloxIPPool struct {
 	Client client.Client
 }
@@ -66,22 +89,26 @@ func (webhook *InfobloxIPPool) Default(_ context.Context, _ runtime.Object) erro
 }
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
-func (webhook *InfobloxIPPool) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
-	pool, ok := obj.(*v1alpha1.InfobloxIPPool)
+func (webhook *InfobloxIPPool) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	pool, ok := obj.(v1alpha1.GenericInfobloxPool)
 	if !ok {
-		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool but got a %T", obj))
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool or GlobalInfobloxIPPool but got a %T", obj))
+	}
+	if err := webhook.validate(pool); err != nil {
+		return nil, err
 	}
-	return nil, webhook.validate(pool)
+	return nil, webhook.validateInstanceAccess(ctx, pool)
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
-func (webhook *InfobloxIPPool) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
-	newPool, ok := newObj.(*v1alpha1.InfobloxIPPool)
+func (webhook *InfobloxIPPool) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	newPool, ok := newObj.(v1alpha1.GenericInfobloxPool)
 	if !ok {
-		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfbloxIPPool but got a %T", newObj))
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool or GlobalInfobloxIPPool but got a %T", newObj))
 	}
-	if _, ok := oldObj.(*v1alpha1.InfobloxIPPool); !ok {
-		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfbloxIPPool but got a %T", oldObj))
+	oldPool, ok := oldObj.(v1alpha1.GenericInfobloxPool)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool or GlobalInfobloxIPPool but got a %T", oldObj))
 	}
 
 	err := webhook.validate(newPool)
@@ -89,17 +116,27 @@ func (webhook *InfobloxIPPool) ValidateUpdate(_ context.Context, oldObj, newObj
 		return nil, err
 	}
 
+	if err := webhook.validateInstanceAccess(ctx, newPool); err != nil {
+		return nil, err
+	}
+
+	if oldPool.PoolSpec().NetworkContainer != newPool.PoolSpec().NetworkContainer {
+		return nil, apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind(newPool.GetObjectKind().GroupVersionKind().Kind).GroupKind(), newPool.GetName(), field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "networkContainer"), "networkContainer is immutable"),
+		})
+	}
+
 	return nil, nil
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
 func (webhook *InfobloxIPPool) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
-	pool, ok := obj.(*v1alpha1.InfobloxIPPool)
+	pool, ok := obj.(v1alpha1.GenericInfobloxPool)
 	if !ok {
-		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool but got a %T", obj))
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an InfobloxIPPool or GlobalInfobloxIPPool but got a %T", obj))
 	}
 
-	if _, ok := pool.GetAnnotations()[SkipValidateDeleteWebhookAnnotation]; ok {
+	if skipValidateDelete(pool) {
 		return nil, nil
 	}
 
@@ -121,7 +158,7 @@ func (webhook *InfobloxIPPool) ValidateDelete(ctx context.Context, obj runtime.O
 	return nil, nil
 }
 
-func (webhook *InfobloxIPPool) validate(newPool *v1alpha1.InfobloxIPPool) (reterr error) {
+func (webhook *InfobloxIPPool) validate(newPool v1alpha1.GenericInfobloxPool) (reterr error) {
 	var allErrs field.ErrorList
 	defer func() {
 		if len(allErrs) > 0 {
@@ -129,45 +166,112 @@ func (webhook *InfobloxIPPool) validate(newPool *v1alpha1.InfobloxIPPool) (reter
 		}
 	}()
 
-	if len(newPool.Spec.Subnets) == 0 {
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "subnets"), newPool.Spec.Subnets, "subnets is required"))
+	spec := newPool.PoolSpec()
+
+	switch {
+	case spec.NetworkContainer.CIDR != "" && len(spec.Subnets) > 0:
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "subnets"), spec.Subnets, "subnets and networkContainer are mutually exclusive"))
+	case spec.NetworkContainer.CIDR != "":
+		allErrs = append(allErrs, validateNetworkContainer(spec.NetworkContainer)...)
+	case len(spec.Subnets) == 0:
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "subnets"), spec.Subnets, "subnets is required"))
+	}
+
+	if spec.AllowedNamespaces != nil {
+		if _, global := newPool.(*v1alpha1.GlobalInfobloxIPPool); !global {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "allowedNamespaces"), "allowedNamespaces is only supported on GlobalInfobloxIPPool"))
+		} else if _, err := metav1.LabelSelectorAsSelector(spec.AllowedNamespaces); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "allowedNamespaces"), spec.AllowedNamespaces, err.Error()))
+		}
+	}
+
+	if spec.Retention.Period.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "retention", "period"), spec.Retention.Period.Duration.String(), "period must not be negative"))
+	}
+
+	if spec.Quarantine.Period.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "quarantine", "period"), spec.Quarantine.Period.Duration.String(), "period must not be negative"))
 	}
 
-	if newPool.Spec.InstanceRef.Name == "" {
+	if spec.InstanceRef.Name == "" {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "InstanceRef.Name"),
-			newPool.Spec.InstanceRef.Name, "InstanceRef.Name is required"))
+			spec.InstanceRef.Name, "InstanceRef.Name is required"))
 	}
 
-	for i, subnet := range newPool.Spec.Subnets {
+	for i, subnet := range spec.Subnets {
 		_, network, err := net.ParseCIDR(subnet.CIDR)
 		if err != nil || network.String() != subnet.CIDR {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", subnetPath(i), "CIDR"),
-				newPool.Spec.Subnets[i].CIDR, subnetPath(i)+".CIDR is not a valid CIDR"))
+				spec.Subnets[i].CIDR, subnetPath(i)+".CIDR is not a valid CIDR"))
 		}
 
 		gatewayIP, err := netip.ParseAddr(subnet.Gateway)
 		if err != nil {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", subnetPath(i), "Gateway"),
-				newPool.Spec.Subnets[i].Gateway, subnetPath(i)+".Gateway is not a valid IP address"+" "+err.Error()))
+				spec.Subnets[i].Gateway, subnetPath(i)+".Gateway is not a valid IP address"+" "+err.Error()))
 		}
 
 		networkIP, err := netip.ParseAddr(network.IP.String())
 		if err != nil {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", subnetPath(i), "CIDR"),
-				newPool.Spec.Subnets[i].CIDR, subnetPath(i)+".CIDR could not be parsed"))
+				spec.Subnets[i].CIDR, subnetPath(i)+".CIDR could not be parsed"))
 		}
 
 		ipVersionsMatched := (networkIP.Is4() && gatewayIP.Is4()) || (networkIP.Is6() && gatewayIP.Is6())
 
 		if !ipVersionsMatched {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", subnetPath(i)),
-				newPool.Spec.Subnets[i].CIDR, "CIDR and gateway are mixed IPv4 and IPv6 addresses"))
+				spec.Subnets[i].CIDR, "CIDR and gateway are mixed IPv4 and IPv6 addresses"))
 		}
 	}
 
 	return //nolint:nakedret
 }
 
+// validateInstanceAccess ensures that the namespace of a pool is allowed to use the referenced InfobloxInstance.
+// Global pools are not bound to a namespace, so their claims are checked by the controller instead.
+func (webhook *InfobloxIPPool) validateInstanceAccess(ctx context.Context, pool v1alpha1.GenericInfobloxPool) error {
+	if pool.GetNamespace() == "" {
+		return nil
+	}
+
+	instance := &v1alpha1.InfobloxInstance{}
+	if err := webhook.Client.Get(ctx, types.NamespacedName{Name: pool.PoolSpec().InstanceRef.Name}, instance); err != nil {
+		if apierrors.IsNotFound(err) {
+			// the pool will not become ready until the instance exists and is checked again by the controller
+			return nil
+		}
+		return apierrors.NewInternalError(err)
+	}
+
+	allowed, err := poolutil.NamespaceAllowed(ctx, webhook.Client, instance.Spec.AllowedNamespaces, pool.GetNamespace())
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	if !allowed {
+		return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind(pool.GetObjectKind().GroupVersionKind().Kind).GroupKind(), pool.GetName(), field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "instanceRef", "name"), fmt.Sprintf("namespace %q is not allowed to use instance %q", pool.GetNamespace(), instance.Name)),
+		})
+	}
+	return nil
+}
+
+func validateNetworkContainer(nc v1alpha1.NetworkContainer) field.ErrorList {
+	var allErrs field.ErrorList
+
+	container, err := netip.ParsePrefix(nc.CIDR)
+	if err != nil || container.Masked() != container {
+		return append(allErrs, field.Invalid(field.NewPath("spec", "networkContainer", "cidr"), nc.CIDR, "cidr is not a valid CIDR"))
+	}
+
+	if int(nc.PrefixLength) <= container.Bits() || int(nc.PrefixLength) > container.Addr().BitLen() {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "networkContainer", "prefixLength"), nc.PrefixLength,
+			fmt.Sprintf("prefixLength must be between %d and %d", container.Bits()+1, container.Addr().BitLen())))
+	}
+
+	return allErrs
+}
+
 func subnetPath(i int) string {
 	return fmt.Sprintf("Subnet[%d]", i)
 }
@@ -19,13 +19,16 @@ package webhooks
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/index"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -37,6 +40,7 @@ func TestCreatingPool(t *testing.T) {
 
 	scheme := runtime.NewScheme()
 	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
 
 	namespacedPool := &v1alpha1.InfobloxIPPool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -76,6 +80,7 @@ func TestPoolDeletionWithExistingIPAddresses(t *testing.T) {
 
 	scheme := runtime.NewScheme()
 	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
 
 	namespacedPool := &v1alpha1.InfobloxIPPool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -117,6 +122,7 @@ func TestPoolDeletionWithExistingIPAddressesAndDeletionSkipAnnotation(t *testing
 
 	scheme := runtime.NewScheme()
 	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
 
 	namespacedPool := &v1alpha1.InfobloxIPPool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -150,14 +156,68 @@ func TestPoolDeletionWithExistingIPAddressesAndDeletionSkipAnnotation(t *testing
 	_, err := webhook.ValidateDelete(ctx, namespacedPool)
 	g.Expect(err).ToNot(HaveOccurred(), "should not allow deletion when claims exist")
 
+	namespacedPool.Annotations = map[string]string{clusterctlv1.DeleteForMoveAnnotation: ""}
+	_, err = webhook.ValidateDelete(ctx, namespacedPool)
+	g.Expect(err).ToNot(HaveOccurred(), "should allow deletion by clusterctl move when claims exist")
+
 	g.Expect(fakeClient.DeleteAllOf(ctx, &ipamv1.IPAddress{})).To(Succeed())
 }
 
+func TestGlobalPoolDeletionWithExistingIPAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	globalPool := &v1alpha1.GlobalInfobloxIPPool{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GlobalInfobloxIPPool",
+			APIVersion: v1alpha1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-pool",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: "test-instance"},
+			Subnets:     []v1alpha1.Subnet{{CIDR: "192.168.1.0/24", Gateway: "192.168.1.1"}},
+		},
+	}
+
+	ipA := createIP("address00", "192.168.1.2", globalPool)
+	ipA.Namespace = "test-namespace-a"
+	ipB := createIP("address01", "192.168.1.3", globalPool)
+	ipB.Namespace = "test-namespace-b"
+	ips := []client.Object{ipA, ipB}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ips...).
+		WithIndex(&ipamv1.IPAddress{}, index.IPAddressPoolRefCombinedField, index.IPAddressByCombinedPoolRef).
+		Build()
+
+	webhook := InfobloxIPPool{
+		Client: fakeClient,
+	}
+
+	g.Expect(testCreate(ctx, globalPool, &webhook)).To(Succeed())
+
+	_, err := webhook.ValidateDelete(ctx, globalPool)
+	g.Expect(err).To(HaveOccurred(), "should not allow deletion when claims exist in any namespace")
+
+	g.Expect(fakeClient.DeleteAllOf(ctx, &ipamv1.IPAddress{}, client.InNamespace("test-namespace-a"))).To(Succeed())
+	g.Expect(fakeClient.DeleteAllOf(ctx, &ipamv1.IPAddress{}, client.InNamespace("test-namespace-b"))).To(Succeed())
+
+	_, err = webhook.ValidateDelete(ctx, globalPool)
+	g.Expect(err).ToNot(HaveOccurred(), "should allow deletion when no claims exist")
+}
+
 func TestUpdatingPool(t *testing.T) {
 	g := NewWithT(t)
 
 	scheme := runtime.NewScheme()
 	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
 
 	namespacedPool := &v1alpha1.InfobloxIPPool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -192,6 +252,92 @@ func TestUpdatingPool(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred(), "should not allow removing in use IPs from addresses field in pool")
 }
 
+func TestUpdatingPoolNetworkContainer(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	namespacedPool := &v1alpha1.InfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pool",
+			Namespace: "test-namespace",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef:      v1alpha1.InstanceReference{Name: "test-instance"},
+			NetworkContainer: v1alpha1.NetworkContainer{CIDR: "10.0.0.0/16", PrefixLength: 24},
+		},
+	}
+
+	webhook := InfobloxIPPool{
+		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+	}
+
+	g.Expect(testCreate(ctx, namespacedPool, &webhook)).To(Succeed())
+
+	oldNamespacedPool := namespacedPool.DeepCopyObject()
+	namespacedPool.Spec.NetworkContainer.PrefixLength = 26
+
+	_, err := webhook.ValidateUpdate(ctx, oldNamespacedPool, namespacedPool)
+	g.Expect(err).To(MatchError(ContainSubstring("networkContainer is immutable")))
+}
+
+func TestPoolNamespaceNotAllowedByInstance(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	instance := &v1alpha1.InfobloxInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-instance",
+		},
+		Spec: v1alpha1.InfobloxInstanceSpec{
+			AllowedNamespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"infoblox": "allowed"}},
+		},
+	}
+	allowedNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "allowed", Labels: map[string]string{"infoblox": "allowed"}},
+	}
+	deniedNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "denied"},
+	}
+
+	webhook := InfobloxIPPool{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(instance, allowedNamespace, deniedNamespace).
+			Build(),
+	}
+
+	pool := &v1alpha1.InfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pool",
+			Namespace: "allowed",
+		},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: "test-instance"},
+			Subnets:     []v1alpha1.Subnet{{CIDR: "192.168.1.0/24", Gateway: "192.168.1.1"}},
+		},
+	}
+	g.Expect(testCreate(ctx, pool, &webhook)).To(Succeed())
+
+	pool.Namespace = "denied"
+	g.Expect(testCreate(ctx, pool, &webhook)).To(MatchError(ContainSubstring(`namespace "denied" is not allowed to use instance "test-instance"`)))
+	g.Expect(testUpdate(ctx, pool, &webhook)).To(MatchError(ContainSubstring(`namespace "denied" is not allowed to use instance "test-instance"`)))
+
+	globalPool := &v1alpha1.GlobalInfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-global-pool",
+		},
+		Spec: pool.Spec,
+	}
+	g.Expect(testCreate(ctx, globalPool, &webhook)).To(Succeed(), "global pools are checked for each claim")
+}
+
 type invalidScenarioTest struct {
 	testcase      string
 	spec          v1alpha1.InfobloxIPPoolSpec
@@ -248,6 +394,66 @@ func TestInvalidScenarios(t *testing.T) {
 			},
 			expectedError: "CIDR and gateway are mixed IPv4 and IPv6 addresses",
 		},
+		{
+			testcase: "negative retention period should not be allowed",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets:     []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+				InstanceRef: v1alpha1.InstanceReference{Name: "test-instance"},
+				Retention:   v1alpha1.Retention{Period: metav1.Duration{Duration: -time.Hour}},
+			},
+			expectedError: "period must not be negative",
+		},
+		{
+			testcase: "negative quarantine period should not be allowed",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets:     []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+				InstanceRef: v1alpha1.InstanceReference{Name: "test-instance"},
+				Quarantine:  v1alpha1.Quarantine{Period: metav1.Duration{Duration: -time.Minute}},
+			},
+			expectedError: "period must not be negative",
+		},
+		{
+			testcase: "subnets and networkContainer should not be allowed together",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets:          []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+				NetworkContainer: v1alpha1.NetworkContainer{CIDR: "10.0.0.0/16", PrefixLength: 24},
+				InstanceRef:      v1alpha1.InstanceReference{Name: "test-instance"},
+			},
+			expectedError: "subnets and networkContainer are mutually exclusive",
+		},
+		{
+			testcase: "invalid networkContainer CIDR should not be allowed",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				NetworkContainer: v1alpha1.NetworkContainer{CIDR: "10.0.0.1/16", PrefixLength: 24},
+				InstanceRef:      v1alpha1.InstanceReference{Name: "test-instance"},
+			},
+			expectedError: "cidr is not a valid CIDR",
+		},
+		{
+			testcase: "networkContainer prefixLength must be longer than the container prefix",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				NetworkContainer: v1alpha1.NetworkContainer{CIDR: "10.0.0.0/16", PrefixLength: 16},
+				InstanceRef:      v1alpha1.InstanceReference{Name: "test-instance"},
+			},
+			expectedError: "prefixLength must be between 17 and 32",
+		},
+		{
+			testcase: "networkContainer prefixLength must fit the address family",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				NetworkContainer: v1alpha1.NetworkContainer{CIDR: "10.0.0.0/16", PrefixLength: 64},
+				InstanceRef:      v1alpha1.InstanceReference{Name: "test-instance"},
+			},
+			expectedError: "prefixLength must be between 17 and 32",
+		},
+		{
+			testcase: "allowedNamespaces should not be allowed on namespaced pools",
+			spec: v1alpha1.InfobloxIPPoolSpec{
+				Subnets:           []v1alpha1.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"}},
+				InstanceRef:       v1alpha1.InstanceReference{Name: "test-instance"},
+				AllowedNamespaces: &metav1.LabelSelector{},
+			},
+			expectedError: "allowedNamespaces is only supported on GlobalInfobloxIPPool",
+		},
 	}
 	for _, tt := range tests {
 		namespacedPool := &v1alpha1.InfobloxIPPool{Spec: tt.spec}
@@ -255,6 +461,7 @@ func TestInvalidScenarios(t *testing.T) {
 		g := NewWithT(t)
 		scheme := runtime.NewScheme()
 		g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+		g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
 
 		webhook := InfobloxIPPool{
 			Client: fake.NewClientBuilder().
@@ -326,7 +533,7 @@ func testUpdate(ctx context.Context, obj runtime.Object, webhook customDefaulter
 	return err
 }
 
-func createIP(name string, ip string, pool *v1alpha1.InfobloxIPPool) *ipamv1.IPAddress {
+func createIP(name string, ip string, pool v1alpha1.GenericInfobloxPool) *ipamv1.IPAddress {
 	return &ipamv1.IPAddress{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "IPAddress",
@@ -334,7 +541,7 @@ func createIP(name string, ip string, pool *v1alpha1.InfobloxIPPool) *ipamv1.IPA
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: pool.Namespace,
+			Namespace: pool.GetNamespace(),
 		},
 		Spec: ipamv1.IPAddressSpec{
 			PoolRef: ipamv1.IPPoolReference{
@@ -22,6 +22,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
@@ -70,6 +71,9 @@ func main() {
 		probeAddr            string
 		watchNamespace       string
 		watchFilter          string
+		healthCheckInterval  time.Duration
+		auditLogPath         string
+		dryRun               bool
 
 		managerOptions = flags.ManagerOptions{}
 
@@ -86,6 +90,12 @@ func main() {
 	flag.StringVar(&watchNamespace, "namespace", "",
 		"Namespace that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces.")
 	flag.StringVar(&watchFilter, "watch-filter", "", "")
+	flag.DurationVar(&healthCheckInterval, "instance-health-check-interval", controllers.DefaultHealthCheckInterval,
+		"Interval at which the connection to the Infoblox instances is checked.")
+	flag.StringVar(&auditLogPath, "audit-log", "",
+		"Path of a file the changes made in Infoblox are appended to as JSON lines, or - for stdout. Auditing is disabled if unspecified.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Log the objects that would be created, updated or deleted in Infoblox instead of changing them. Objects are still read from Infoblox.")
 	flag.IntVar(&webhookOpts.Port, "webhook-port", webhook.DefaultPort,
 		"Webhook Server port")
 	flag.StringVar(&webhookOpts.CertDir, "webhook-cert-dir", "",
@@ -148,13 +158,37 @@ func main() {
 
 	podNamespace := os.Getenv("NAMESPACE")
 
+	var auditLog *infoblox.AuditLog
+	if auditLogPath != "" {
+		auditLog, err = infoblox.OpenAuditLog(auditLogPath)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log")
+			os.Exit(1)
+		}
+	}
+
+	newInfobloxClient := infoblox.NewClient
+	if dryRun {
+		setupLog.Info("running in dry-run mode, no changes are made in Infoblox")
+		newInfobloxClient = func(config infoblox.Config) (infoblox.Client, error) {
+			config.DryRun = true
+			return infoblox.NewClient(config)
+		}
+	}
+	credentialSources := controllers.NewCredentialSources()
+	rateLimiters := controllers.NewRateLimiters()
+
 	if err = (&ipamutil.ClaimReconciler{
 		Client:           mgr.GetClient(),
 		Scheme:           mgr.GetScheme(),
 		WatchFilterValue: watchFilter,
 		Adapter: &controllers.InfobloxProviderAdapter{
-			NewInfobloxClientFunc: infoblox.NewClient,
+			NewInfobloxClientFunc: newInfobloxClient,
 			OperatorNamespace:     podNamespace,
+			Recorder:              mgr.GetEventRecorderFor("ipaddressclaim-controller"),
+			AuditLog:              auditLog,
+			CredentialSources:     credentialSources,
+			RateLimiters:          rateLimiters,
 		},
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IPAddressClaim")
@@ -164,8 +198,12 @@ func main() {
 	if err = (&controllers.InfobloxInstanceReconciler{
 		Client:                mgr.GetClient(),
 		Scheme:                mgr.GetScheme(),
-		NewInfobloxClientFunc: infoblox.NewClient,
+		NewInfobloxClientFunc: newInfobloxClient,
 		OperatorNamespace:     podNamespace,
+		HealthCheckInterval:   healthCheckInterval,
+		Recorder:              mgr.GetEventRecorderFor("infobloxinstance-controller"),
+		CredentialSources:     credentialSources,
+		RateLimiters:          rateLimiters,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "InfobloxInstance")
 		os.Exit(1)
@@ -173,17 +211,38 @@ func main() {
 	if err = (&controllers.InfobloxIPPoolReconciler{
 		Client:                mgr.GetClient(),
 		Scheme:                mgr.GetScheme(),
-		NewInfobloxClientFunc: infoblox.NewClient,
+		NewInfobloxClientFunc: newInfobloxClient,
 		OperatorNamespace:     podNamespace,
+		Recorder:              mgr.GetEventRecorderFor("infobloxippool-controller"),
+		AuditLog:              auditLog,
+		CredentialSources:     credentialSources,
+		RateLimiters:          rateLimiters,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "InfobloxIPPool")
 		os.Exit(1)
 	}
+	if err = (&controllers.GlobalInfobloxIPPoolReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		NewInfobloxClientFunc: newInfobloxClient,
+		OperatorNamespace:     podNamespace,
+		Recorder:              mgr.GetEventRecorderFor("globalinfobloxippool-controller"),
+		AuditLog:              auditLog,
+		CredentialSources:     credentialSources,
+		RateLimiters:          rateLimiters,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GlobalInfobloxIPPool")
+		os.Exit(1)
+	}
 
 	if err := (&webhooks.InfobloxIPPool{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "InfobloxIPPool")
 		os.Exit(1)
 	}
+	if err := (&webhooks.InfobloxInstance{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "InfobloxInstance")
+		os.Exit(1)
+	}
 
 	//+kubebuilder:scaffold:builder
 
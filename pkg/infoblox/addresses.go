@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -30,9 +31,8 @@ func (c *client) getOrNewHostRecord(networkView, dnsView, dnsZone, hostname stri
 	var records []ibclient.HostRecord
 	err := c.connector.GetObject(ibclient.NewEmptyHostRecord(), "", ibclient.NewQueryParams(false, params), &records)
 	if err != nil {
-		// since ibclient.NotFoundError has a pointer receiver on it's Error() method, we can't use errors.As() here.
-		if _, ok := err.(*ibclient.NotFoundError); !ok {
-			return nil, tryParseWapiError(err)
+		if !isNotFound(err) {
+			return nil, classifyError(err)
 		}
 		// not found -> return new preconfigured hostRecord
 		hostRecord := ibclient.NewEmptyHostRecord()
@@ -54,8 +54,10 @@ func (c *client) getOrNewHostRecord(networkView, dnsView, dnsZone, hostname stri
 // createOrUpdateHostRecord creates or updates a host record and then fetches the updated record.
 func (c *client) createOrUpdateHostRecord(hr *ibclient.HostRecord, logger logr.Logger) error {
 	ref := ""
+	operation := ChangeUpdate
 	var err error
 	if hr.Ref == "" {
+		operation = ChangeCreate
 		logger.Info("Creating Infoblox host record", "hostname", *hr.Name)
 		ref, err = c.connector.CreateObject(hr)
 	} else {
@@ -64,15 +66,38 @@ func (c *client) createOrUpdateHostRecord(hr *ibclient.HostRecord, logger logr.L
 		ref, err = c.connector.UpdateObject(hr, hr.Ref)
 	}
 
+	change := Change{Operation: operation, ObjectType: hr.ObjectType(), Name: *hr.Name, Ref: ref}
 	if err != nil {
-		return tryParseWapiError(err)
+		change.Addresses = hostRecordAddresses(hr)
+		c.recordChange(change, err)
+		return classifyError(err)
 	}
 
 	logger.Info("Fetching Infoblox host record", "hostname", *hr.Name)
 	params := map[string]string{
 		"_return_fields": strings.Join(hostRecordReturnFields, ","),
 	}
-	return tryParseWapiError(c.connector.GetObject(hr, ref, ibclient.NewQueryParams(false, params), hr))
+	err = c.connector.GetObject(hr, ref, ibclient.NewQueryParams(false, params), hr)
+	// If fetching the record fails, the requested addresses are recorded instead of the allocated ones.
+	change.Addresses = hostRecordAddresses(hr)
+	c.recordChange(change, nil)
+	return classifyError(err)
+}
+
+// hostRecordAddresses returns the IPv4 and IPv6 addresses of a host record.
+func hostRecordAddresses(hr *ibclient.HostRecord) []string {
+	var addresses []string
+	for _, ip := range hr.Ipv4Addrs {
+		if ip.Ipv4Addr != nil {
+			addresses = append(addresses, *ip.Ipv4Addr)
+		}
+	}
+	for _, ip := range hr.Ipv6Addrs {
+		if ip.Ipv6Addr != nil {
+			addresses = append(addresses, *ip.Ipv6Addr)
+		}
+	}
+	return addresses
 }
 
 // getAllocatedHostRecordAddrInSubnet returns the first IP address in a host record that is in the given subnet.
@@ -107,18 +132,52 @@ func getAllocatedHostRecordAddrInSubnet(hr *ibclient.HostRecord, subnet netip.Pr
 	return netip.Addr{}
 }
 
+// Allocation is an IP address allocated for a host record.
+type Allocation struct {
+	// Address is the allocated address.
+	Address netip.Addr
+	// Ref is the WAPI reference of the host record.
+	Ref string
+	// Name is the name of the host record, which is the FQDN of the host if DNS is enabled for the record.
+	Name string
+	// DNSView is the DNS view of the host record. It is empty if DNS is not enabled for the record.
+	DNSView string
+	// NetworkView is the network view of the host record.
+	NetworkView string
+}
+
+// newAllocation returns the allocation of an address for a host record.
+func newAllocation(hr *ibclient.HostRecord, addr netip.Addr) Allocation {
+	allocation := Allocation{
+		Address:     addr,
+		Ref:         hr.Ref,
+		Name:        ptr.Deref(hr.Name, ""),
+		NetworkView: hr.NetworkView,
+	}
+	if ptr.Deref(hr.EnableDns, false) {
+		allocation.DNSView = ptr.Deref(hr.View, "")
+	}
+	return allocation
+}
+
 // GetOrAllocateAddress returns the IP address of the given hostname in the given subnet.
 //
 // If the hostname does not have an IP address in the subnet, it will allocate one.
-func (c *client) GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, dnsZone string, logger logr.Logger) (netip.Addr, error) {
+func (c *client) GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, dnsZone string, logger logr.Logger) (Allocation, error) {
+	if subnet.Addr().Is6() {
+		if err := c.requireFeature(FeatureIPv6); err != nil {
+			return Allocation{}, err
+		}
+	}
+
 	hr, err := c.getOrNewHostRecord(networkView, dnsView, dnsZone, hostname)
 	if err != nil {
-		return netip.Addr{}, fmt.Errorf("failed to get or create Infoblox host record: %w", err)
+		return Allocation{}, fmt.Errorf("failed to get or create Infoblox host record: %w", err)
 	}
 
 	allocatedAddr := getAllocatedHostRecordAddrInSubnet(hr, subnet)
 	if allocatedAddr.IsValid() {
-		return allocatedAddr, nil
+		return newAllocation(hr, allocatedAddr), nil
 	}
 
 	if subnet.Addr().Is4() {
@@ -130,14 +189,17 @@ func (c *client) GetOrAllocateAddress(networkView, dnsView string, subnet netip.
 	}
 
 	if err := c.createOrUpdateHostRecord(hr, logger); err != nil {
-		return netip.Addr{}, fmt.Errorf("failed to create or update Infoblox host record: %w", err)
+		return Allocation{}, fmt.Errorf("failed to create or update Infoblox host record: %w", err)
 	}
 
 	allocatedAddr = getAllocatedHostRecordAddrInSubnet(hr, subnet)
+	if !allocatedAddr.IsValid() && c.dryRun {
+		return Allocation{}, fmt.Errorf("%w: would allocate the next available address in subnet %s for host record %q", ErrDryRun, subnet, hostname)
+	}
 	if !allocatedAddr.IsValid() {
-		return netip.Addr{}, errors.New("failed to allocate IP address: Infoblox host record does not contain a matching IP address")
+		return Allocation{}, errors.New("failed to allocate IP address: Infoblox host record does not contain a matching IP address")
 	}
-	return allocatedAddr, nil
+	return newAllocation(hr, allocatedAddr), nil
 }
 
 func nextAvailableIBFunc(subnet netip.Prefix, view string) string {
@@ -150,30 +212,74 @@ func (c *client) ReleaseAddress(networkView, dnsView string, subnet netip.Prefix
 	if err != nil {
 		return err
 	}
+	return c.removeHostRecordAddress(hr, subnet.Contains, logger)
+}
+
+// ReleaseAddressByRef releases the given IP address from the host record with the given reference.
+// It returns ErrNotFound if the host record doesn't exist or doesn't contain the address.
+func (c *client) ReleaseAddressByRef(ref string, address netip.Addr, logger logr.Logger) error {
+	params := map[string]string{
+		"_return_fields": strings.Join(hostRecordReturnFields, ","),
+	}
+	hr := ibclient.NewEmptyHostRecord()
+	if err := c.connector.GetObject(hr, ref, ibclient.NewQueryParams(false, params), hr); err != nil {
+		return fmt.Errorf("failed to get Infoblox host record: %w", classifyError(err))
+	}
+	if hr.Ref == "" {
+		hr.Ref = ref
+	}
+	// The address might have been removed from the host record and allocated to another host since.
+	if !slices.Contains(hostRecordAddresses(hr), address.String()) {
+		return fmt.Errorf("%w: host record %s does not contain address %s", ErrNotFound, ref, address)
+	}
+	return c.removeHostRecordAddress(hr, func(addr netip.Addr) bool { return addr == address }, logger)
+}
 
+// GetAddressByRef returns the allocation of the given IP address in the host record with the given reference.
+// It returns ErrNotFound if the host record doesn't exist or doesn't contain the address.
+func (c *client) GetAddressByRef(ref string, address netip.Addr) (Allocation, error) {
+	params := map[string]string{
+		"_return_fields": strings.Join(hostRecordReturnFields, ","),
+	}
+	hr := ibclient.NewEmptyHostRecord()
+	if err := c.connector.GetObject(hr, ref, ibclient.NewQueryParams(false, params), hr); err != nil {
+		return Allocation{}, fmt.Errorf("failed to get Infoblox host record: %w", classifyError(err))
+	}
+	if hr.Ref == "" {
+		hr.Ref = ref
+	}
+	if !slices.Contains(hostRecordAddresses(hr), address.String()) {
+		return Allocation{}, fmt.Errorf("%w: host record %s does not contain address %s", ErrNotFound, ref, address)
+	}
+	return newAllocation(hr, address), nil
+}
+
+// removeHostRecordAddress removes the first address matching the given function from a host record.
+// The host record is deleted if it has no addresses left.
+func (c *client) removeHostRecordAddress(hr *ibclient.HostRecord, matches func(netip.Addr) bool, logger logr.Logger) error {
+	hostname := ptr.Deref(hr.Name, "")
 	removed := false
-	if subnet.Addr().Is4() {
-		for i, ip := range hr.Ipv4Addrs {
-			if ip.Ipv4Addr != nil {
-				nip, err := netip.ParseAddr(*ip.Ipv4Addr)
-				if err != nil {
-					continue
-				}
-				if subnet.Contains(nip) {
-					hr.Ipv4Addrs = append(hr.Ipv4Addrs[:i], hr.Ipv4Addrs[i+1:]...)
-					removed = true
-					break
-				}
+	for i, ip := range hr.Ipv4Addrs {
+		if ip.Ipv4Addr != nil {
+			nip, err := netip.ParseAddr(*ip.Ipv4Addr)
+			if err != nil {
+				continue
+			}
+			if matches(nip) {
+				hr.Ipv4Addrs = append(hr.Ipv4Addrs[:i], hr.Ipv4Addrs[i+1:]...)
+				removed = true
+				break
 			}
 		}
-	} else {
+	}
+	if !removed {
 		for i, ip := range hr.Ipv6Addrs {
 			if ip.Ipv6Addr != nil {
 				nip, err := netip.ParseAddr(*ip.Ipv6Addr)
 				if err != nil {
 					continue
 				}
-				if subnet.Contains(nip) {
+				if matches(nip) {
 					hr.Ipv6Addrs = append(hr.Ipv6Addrs[:i], hr.Ipv6Addrs[i+1:]...)
 					removed = true
 					break
@@ -189,15 +295,19 @@ func (c *client) ReleaseAddress(networkView, dnsView string, subnet netip.Prefix
 
 	if len(hr.Ipv4Addrs) == 0 && len(hr.Ipv6Addrs) == 0 {
 		logger.Info("Deleting Infoblox host record", "hostname", hostname)
-		if _, err := c.connector.DeleteObject(hr.Ref); err != nil {
-			return fmt.Errorf("failed to delete Infoblox host record: %w", tryParseWapiError(err))
+		_, err := c.connector.DeleteObject(hr.Ref)
+		c.recordChange(Change{Operation: ChangeDelete, ObjectType: hr.ObjectType(), Name: hostname, Ref: hr.Ref}, err)
+		if err != nil {
+			return fmt.Errorf("failed to delete Infoblox host record: %w", classifyError(err))
 		}
 		return nil
 	}
 	prepareHostRecordForUpdate(hr)
 	logger.Info("Updating Infoblox host record", "hostname", hostname)
-	if _, err = c.connector.UpdateObject(hr, hr.Ref); err != nil {
-		return fmt.Errorf("failed to update Infoblox host record: %w", tryParseWapiError(err))
+	_, err := c.connector.UpdateObject(hr, hr.Ref)
+	c.recordChange(Change{Operation: ChangeUpdate, ObjectType: hr.ObjectType(), Name: hostname, Ref: hr.Ref, Addresses: hostRecordAddresses(hr)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to update Infoblox host record: %w", classifyError(err))
 	}
 	return nil
 }
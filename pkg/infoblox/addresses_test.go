@@ -1,8 +1,11 @@
 package infoblox
 
 import (
-	"log"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
+	"sync/atomic"
+	"testing"
 
 	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
 	. "github.com/onsi/ginkgo/v2"
@@ -36,16 +39,23 @@ var _ = Describe("IP Address Management", func() {
 		})
 		Context("IPv4", func() {
 			It("creates a new host record and allocates an IP", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
+				changes := recordChanges()
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v4subnet1.Contains(addr)).To(BeTrue())
+				Expect(v4subnet1.Contains(allocation.Address)).To(BeTrue())
+				Expect(*changes).To(ConsistOf(And(
+					HaveField("Operation", ChangeCreate),
+					HaveField("ObjectType", "record:host"),
+					HaveField("Name", hostname),
+					HaveField("Ref", Not(BeEmpty())),
+				)))
 			})
 		})
 		Context("IPv6", func() {
 			It("creates a new host record and allocates an IP", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v6subnet1.Contains(addr)).To(BeTrue())
+				Expect(v6subnet1.Contains(allocation.Address)).To(BeTrue())
 			})
 		})
 	})
@@ -62,13 +72,7 @@ var _ = Describe("IP Address Management", func() {
 				Expect(err).NotTo(HaveOccurred())
 			} else {
 				_, err := testClient.objMgr.GetHostRecordByRef(hostRecord.Ref)
-				Expect(err).To(HaveOccurred())
-				_, ok := err.(*ibclient.NotFoundError)
-				if !ok {
-					logger := log.Default()
-					logger.Printf("Not not found error: %s\n", err.Error())
-				}
-				Expect(ok).To(BeTrue())
+				Expect(err).To(MatchError(ErrNotFound))
 			}
 		})
 
@@ -81,34 +85,58 @@ var _ = Describe("IP Address Management", func() {
 			})
 
 			It("returns the existing IP if the subnet is the same", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(addr.String()).To(BeEquivalentTo(*hostRecord.Ipv4Addrs[0].Ipv4Addr))
+				Expect(allocation.Address.String()).To(BeEquivalentTo(*hostRecord.Ipv4Addrs[0].Ipv4Addr))
 			})
 
 			It("allocates another IP if the subnet is different", func() {
 				Expect(testView).To(Equal(defaultView))
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet2, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet2, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v4subnet2.Contains(addr)).To(BeTrue())
+				Expect(v4subnet2.Contains(allocation.Address)).To(BeTrue())
 			})
 
 			It("allocates an IPv6 address if the subnet is IPv6", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v6subnet1.Contains(addr)).To(BeTrue())
+				Expect(v6subnet1.Contains(allocation.Address)).To(BeTrue())
 			})
 
 			It("deletes the host record when releasing the address", func() {
+				changes := recordChanges()
 				err := testClient.ReleaseAddress(testView, testView, v4subnet1, hostname, logger)
 				Expect(err).NotTo(HaveOccurred())
 				hrDeleted = true
+				Expect(*changes).To(ConsistOf(Change{Operation: ChangeDelete, ObjectType: "record:host", Name: hostname, Ref: hostRecord.Ref}))
 			})
 
 			It("doesnt change the host record when releasing an address in a different subnet", func() {
 				err := testClient.ReleaseAddress(testView, testView, v4subnet2, hostname, logger)
 				Expect(err).NotTo(HaveOccurred())
 			})
+
+			It("deletes the host record when releasing the address by reference", func() {
+				addr := netip.MustParseAddr(*hostRecord.Ipv4Addrs[0].Ipv4Addr)
+				err := testClient.ReleaseAddressByRef(hostRecord.Ref, addr, logger)
+				Expect(err).NotTo(HaveOccurred())
+				hrDeleted = true
+				Expect(testClient.ReleaseAddressByRef(hostRecord.Ref, addr, logger)).To(MatchError(ErrNotFound))
+			})
+
+			It("returns the address of the host record by reference", func() {
+				addr := netip.MustParseAddr(*hostRecord.Ipv4Addrs[0].Ipv4Addr)
+				allocation, err := testClient.GetAddressByRef(hostRecord.Ref, addr)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(allocation).To(SatisfyAll(
+					HaveField("Address", addr),
+					HaveField("Ref", hostRecord.Ref),
+					HaveField("Name", hostname),
+				))
+
+				_, err = testClient.GetAddressByRef(hostRecord.Ref, addr.Next())
+				Expect(err).To(MatchError(ErrNotFound))
+			})
 		})
 
 		Context("IPv6 record", func() {
@@ -120,21 +148,21 @@ var _ = Describe("IP Address Management", func() {
 			})
 
 			It("returns the existing IP if the subnet is the same", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(addr).To(Equal(netip.MustParseAddr(*hostRecord.Ipv6Addrs[0].Ipv6Addr)))
+				Expect(allocation.Address).To(Equal(netip.MustParseAddr(*hostRecord.Ipv6Addrs[0].Ipv6Addr)))
 			})
 
 			It("allocates another IP if the subnet is different", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet2, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v6subnet2, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v6subnet2.Contains(addr)).To(BeTrue())
+				Expect(v6subnet2.Contains(allocation.Address)).To(BeTrue())
 			})
 
 			It("allocates an IPv4 address if the subnet is IPv4", func() {
-				addr, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
+				allocation, err := testClient.GetOrAllocateAddress(testView, testView, v4subnet1, hostname, "", logger)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(v4subnet1.Contains(addr)).To(BeTrue())
+				Expect(v4subnet1.Contains(allocation.Address)).To(BeTrue())
 			})
 
 			It("deletes the host record when releasing the address", func() {
@@ -264,3 +292,40 @@ var _ = Describe("IP Address Management", func() {
 		})
 	})
 })
+
+// recordChanges collects the changes reported by the test client until the end of the spec.
+func recordChanges() *[]Change {
+	changes := &[]Change{}
+	testClient.onChange = func(change Change) {
+		*changes = append(*changes, change)
+	}
+	DeferCleanup(func() {
+		testClient.onChange = nil
+	})
+	return changes
+}
+
+func TestReleaseAddressByRefKeepsHostRecordWithoutAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	const ref = "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:host.example.com/default"
+	var modified atomic.Bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			modified.Store(true)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// The host record still exists, but its address has been replaced.
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"_ref":"` + ref + `","name":"host.example.com","ipv4addrs":[{"ipv4addr":"10.0.0.7"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := NewClient(testConfig(t, server))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = c.ReleaseAddressByRef(ref, netip.MustParseAddr("10.0.0.5"), klog.TODO())
+	g.Expect(err).To(MatchError(ErrNotFound))
+	g.Expect(modified.Load()).To(BeFalse(), "should not update or delete the host record")
+}
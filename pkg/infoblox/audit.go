@@ -0,0 +1,87 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditResultSuccess is the result of an audit record for a successful change.
+	AuditResultSuccess = "success"
+	// AuditResultFailure is the result of an audit record for a failed change.
+	AuditResultFailure = "failure"
+	// AuditResultDryRun is the result of an audit record for a change that has not been made in dry-run mode.
+	AuditResultDryRun = "dry-run"
+)
+
+// AuditContext describes on whose behalf a client changes objects in Infoblox.
+type AuditContext struct {
+	// Log receives a record for every object the client creates, updates or deletes. Auditing is disabled if it is nil.
+	Log *AuditLog
+	// Claim is the namespace/name of the IPAddressClaim the changes are made for, if any.
+	Claim string
+	// Cluster is the name of the Cluster the claim belongs to, if any.
+	Cluster string
+	// Pool is the namespace/name, or the name of a cluster-scoped pool, the changes are made for.
+	Pool string
+}
+
+// AuditRecord is a single line of the audit log.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"`
+	ObjectType string    `json:"objectType"`
+	// Ref is the WAPI reference of the object, so records can be correlated with the audit log of the grid.
+	Ref       string   `json:"ref,omitempty"`
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Instance is the host of the grid master the request was sent to.
+	Instance string `json:"instance"`
+	// User is the WAPI user of the request. It is empty if the credentials are read from a credentials source.
+	User    string `json:"user,omitempty"`
+	Claim   string `json:"claim,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+	Pool    string `json:"pool,omitempty"`
+	// Result is one of AuditResultSuccess, AuditResultFailure or AuditResultDryRun.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditLog writes audit records as JSON lines. It is safe for concurrent use.
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewAuditLog returns an AuditLog that writes to w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// OpenAuditLog returns an AuditLog that appends to the file at path, or writes to stdout if path is "-".
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if path == "-" {
+		return NewAuditLog(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return NewAuditLog(f), nil
+}
+
+// Record writes a record to the log. The time of the record is set if it is empty.
+func (l *AuditLog) Record(record AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = l.now().UTC()
+	}
+	return l.enc.Encode(record)
+}
@@ -0,0 +1,93 @@
+package infoblox
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+)
+
+func TestAuditLog(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+	log.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	g.Expect(log.Record(AuditRecord{Operation: ChangeCreate, ObjectType: "record:host", Name: "host.example.com", Result: AuditResultSuccess})).To(Succeed())
+	g.Expect(log.Record(AuditRecord{Operation: ChangeDelete, ObjectType: "network", Name: "10.0.0.0/24", Result: AuditResultFailure, Error: "boom"})).To(Succeed())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(string(lines[0])).To(Equal(`{"time":"2024-05-01T12:00:00Z","operation":"Create","objectType":"record:host","name":"host.example.com","instance":"","result":"success"}`))
+	g.Expect(string(lines[1])).To(ContainSubstring(`"result":"failure","error":"boom"`))
+}
+
+func TestClientAuditsChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	const ref = "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:host.example.com/default"
+	deleteStatus := http.StatusOK
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wapi/v2.12/record:host", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"_ref":"` + ref + `","name":"host.example.com","ipv4addrs":[{"ipv4addr":"10.0.0.2"}]}]`))
+	})
+	mux.HandleFunc("/wapi/v2.12/record:host/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(deleteStatus)
+		if deleteStatus != http.StatusOK {
+			_, _ = w.Write([]byte(`{"code":"Client.Ibap.Proto","text":"permission denied"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`"` + ref + `"`))
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	config := testConfig(t, server)
+	config.Audit = AuditContext{Log: NewAuditLog(&buf), Claim: "default/claim", Cluster: "cluster", Pool: "default/pool"}
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	subnet := netip.MustParsePrefix("10.0.0.0/24")
+	g.Expect(c.ReleaseAddress("default", "default", subnet, "host.example.com", klog.TODO())).To(Succeed())
+	deleteStatus = http.StatusBadRequest
+	g.Expect(c.ReleaseAddress("default", "default", subnet, "host.example.com", klog.TODO())).NotTo(Succeed())
+
+	var records []AuditRecord
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record AuditRecord
+		g.Expect(decoder.Decode(&record)).To(Succeed())
+		records = append(records, record)
+	}
+	g.Expect(records).To(HaveLen(2))
+	g.Expect(records[0].Time).NotTo(BeZero())
+	records[0].Time = time.Time{}
+	g.Expect(records[0]).To(Equal(AuditRecord{
+		Operation:  ChangeDelete,
+		ObjectType: "record:host",
+		Ref:        ref,
+		Name:       "host.example.com",
+		Instance:   config.Host,
+		User:       "admin",
+		Claim:      "default/claim",
+		Cluster:    "cluster",
+		Pool:       "default/pool",
+		Result:     AuditResultSuccess,
+	}))
+	g.Expect(records[1].Result).To(Equal(AuditResultFailure))
+	g.Expect(records[1].Error).To(ContainSubstring("permission denied"))
+}
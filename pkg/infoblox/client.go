@@ -2,15 +2,15 @@
 package infoblox
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/netip"
-	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
 )
 
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
@@ -26,91 +26,187 @@ const (
 
 // Client is a wrapper around the infoblox client that can allocate and release addresses indempotently.
 type Client interface {
-	// GetOrAllocateAddress allocates an address for a given hostname if none exists, and returns the new or existing address.
-	GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, zone string, logger logr.Logger) (netip.Addr, error)
+	// GetOrAllocateAddress allocates an address for a given hostname if none exists, and returns the new or existing address
+	// together with the host record it belongs to.
+	GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, zone string, logger logr.Logger) (Allocation, error)
 	// ReleaseAddress releases an address for a given hostname.
 	ReleaseAddress(networkView, dnsView string, subnet netip.Prefix, hostname string, logger logr.Logger) error
+	// ReleaseAddressByRef releases an address from the host record with the given WAPI reference.
+	// ErrNotFound is returned if the host record does not exist or does not contain the address.
+	ReleaseAddressByRef(ref string, address netip.Addr, logger logr.Logger) error
+	// GetAddressByRef returns the allocation of the given IP address in the host record with the given WAPI reference.
+	// ErrNotFound is returned if the host record does not exist or does not contain the address.
+	GetAddressByRef(ref string, address netip.Addr) (Allocation, error)
+	// QuarantineAddress reserves a released IPv4 address for owner until the given time.
+	QuarantineAddress(networkView string, address netip.Addr, name, owner string, until time.Time, logger logr.Logger) error
+	// ReleaseQuarantinedAddresses deletes the expired reservations of owner and returns when the next one expires.
+	ReleaseQuarantinedAddresses(networkView, owner string, now time.Time, logger logr.Logger) (time.Time, error)
 	// CheckNetworkViewExists checks if Infoblox network view exists
 	CheckNetworkViewExists(view string) (bool, error)
 	// CheckDNSViewExists checks if Infoblox DNS view exists
 	CheckDNSViewExists(view string) (bool, error)
 	// CheckNetworkExists checks if Infoblox network exists
 	CheckNetworkExists(view string, subnet netip.Prefix) (bool, error)
+	// GetOrAllocateNetwork returns the network owned by owner in the given network container, and allocates a new one if none exists.
+	GetOrAllocateNetwork(view string, container netip.Prefix, prefixLength int, owner string, logger logr.Logger) (netip.Prefix, error)
+	// ReleaseNetwork deletes a network if it is owned by owner.
+	ReleaseNetwork(view string, subnet netip.Prefix, owner string, logger logr.Logger) error
+	// GetGridInfo probes the WAPI and returns information about the grid.
+	GetGridInfo() (GridInfo, error)
+	// GetSchema queries the WAPI versions supported by the grid and the objects supported in the given version.
+	GetSchema(version WAPIVersion) (Schema, error)
 	GetHostConfig() *HostConfig
 }
 
 type client struct {
-	connector *ibclient.Connector
+	connector ibclient.IBConnector
 	objMgr    ibclient.IBObjectManager
+	requestor *requestor
 	hc        HostConfig
+	auth      AuthConfig
+	onChange  func(Change)
+	audit     AuditContext
+	dryRun    bool
 }
 
 var _ Client = &client{}
 
 // AuthConfig contains authentication parameters to use for authenticating against the API.
+// If a Source is set, the credentials are fetched from it for every request and the other fields are ignored.
 type AuthConfig struct {
 	Username   string
 	Password   string
 	ClientCert []byte
 	ClientKey  []byte
+	Source     CredentialSource
 }
 
 // HostConfig contains host configuration patameters.
 type HostConfig struct {
 	Host                   string
+	Port                   string
+	PathPrefix             string
 	Version                string
 	DisableTLSVerification bool
 	CustomCAPath           string
+	CustomCA               []byte
+	ProxyURL               string
+	NoProxy                []string
 	DefaultNetworkView     string
 	DefaultDNSView         string
+	// Features supported by the grid, see GetSchema. If nil, all features are assumed to be supported.
+	Features []string
+	// FailoverEndpoints are further grid members that requests are sent to if the host is unavailable.
+	FailoverEndpoints []Endpoint
+	// PreferredEndpoint is the endpoint, in the format host:port, that requests are sent to first, e.g. the last active one.
+	PreferredEndpoint string
+	// RateLimiter limits the requests sent to the grid. It should be shared by all clients of a grid. Requests are not limited if nil.
+	RateLimiter *rate.Limiter
+	// RequestTimeout is the timeout of a single request. Defaults to DefaultRequestTimeout.
+	RequestTimeout time.Duration
+	// Retry configures how throttled requests and requests that failed with a server error are retried. They are not retried if unset.
+	Retry RetryConfig
+}
+
+// RetryConfig configures how requests are retried. The backoff is doubled for every retry
+// and defaults to the initial backoff of DefaultRetryConfig.
+type RetryConfig struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Endpoint is the address of a grid member. If the port is empty, the port of the host config is used.
+type Endpoint struct {
+	Host string
+	Port string
 }
 
 // Config is a wrapper config structures.
 type Config struct {
 	HostConfig
 	AuthConfig
+	// OnChange is called after the client created, updated or deleted an object in Infoblox.
+	OnChange func(Change)
+	// Audit configures the audit log of changes made by the client.
+	Audit AuditContext
+	// DryRun makes the client log the objects it would create, update or delete instead of sending the requests to Infoblox.
+	// Objects are still read from Infoblox.
+	DryRun bool
+}
+
+const (
+	// ChangeCreate is the operation of a Change that created an object.
+	ChangeCreate = "Create"
+	// ChangeUpdate is the operation of a Change that updated an object.
+	ChangeUpdate = "Update"
+	// ChangeDelete is the operation of a Change that deleted an object.
+	ChangeDelete = "Delete"
+)
+
+// Change describes an object the client created, updated or deleted in Infoblox.
+type Change struct {
+	// Operation is one of ChangeCreate, ChangeUpdate or ChangeDelete.
+	Operation string
+	// ObjectType is the WAPI object type, e.g. record:host.
+	ObjectType string
+	// Name is the name of the object, e.g. the hostname of a host record or the CIDR of a network.
+	Name string
+	// Ref is the WAPI reference of the object.
+	Ref string
+	// Addresses are the IP addresses of a host record after the change.
+	Addresses []string
+	// DryRun is true if the change has not been made because the client is in dry-run mode.
+	DryRun bool
 }
 
 // NewClient creates a new infoblox client.
 func NewClient(config Config) (Client, error) {
+	host, port := endpoint(config.HostConfig)
 	hc := ibclient.HostConfig{
+		Host:    host,
+		Port:    port,
 		Version: config.Version,
 	}
-	hostParts := strings.SplitN(config.Host, ":", 2)
-	hc.Host = hostParts[0]
-	if len(hostParts) == 2 {
-		hc.Port = hostParts[1]
-	} else {
-		hc.Port = "443"
-	}
-	ac := ibclient.AuthConfig{
-		Username:   config.Username,
-		Password:   config.Password,
-		ClientCert: config.ClientCert,
-		ClientKey:  config.ClientKey,
+	// Credentials from a source are added by the requestor, so they can change between requests.
+	ac := ibclient.AuthConfig{}
+	if config.Source == nil {
+		ac = ibclient.AuthConfig{
+			Username:   config.Username,
+			Password:   config.Password,
+			ClientCert: config.ClientCert,
+			ClientKey:  config.ClientKey,
+		}
 	}
-	tlsVerify := "true"
-	if config.DisableTLSVerification {
-		tlsVerify = "false"
-	} else if config.CustomCAPath != "" {
-		tlsVerify = config.CustomCAPath
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
-	rb := &ibclient.WapiRequestBuilder{}
-	rq := &ibclient.WapiHttpRequestor{}
-	tc := ibclient.NewTransportConfig(tlsVerify, int(time.Second), 5)
-	con, err := ibclient.NewConnector(hc, ac, tc, rb, rq)
+	rb := &requestBuilder{pathPrefix: config.PathPrefix}
+	rq := newRequestor(httpClient, config.HostConfig, config.Source)
+	con, err := ibclient.NewConnector(hc, ac, ibclient.TransportConfig{}, rb, rq)
 	if err != nil {
 		// does not happen with the current infoblox-go-client
 		return nil, err
 	}
 
-	objMgr := ibclient.NewObjectManager(con, "cluster-api-ipam-provider-infoblox", "")
+	var connector ibclient.IBConnector = con
+	if config.DryRun {
+		connector = newDryRunConnector(con, klog.Background().WithName("infoblox-dry-run").WithValues("host", config.Host))
+	}
+	objMgr := ibclient.NewObjectManager(connector, "cluster-api-ipam-provider-infoblox", "")
 
 	return &client{
-		connector: con,
+		connector: connector,
 		objMgr:    objMgr,
+		requestor: rq,
 		hc:        config.HostConfig,
+		auth:      config.AuthConfig,
+		onChange:  config.OnChange,
+		audit:     config.Audit,
+		dryRun:    config.DryRun,
 	}, nil
 }
 
@@ -131,14 +227,16 @@ func AuthConfigFromSecretData(data map[string][]byte) (AuthConfig, error) {
 }
 
 func (c *client) CheckNetworkViewExists(view string) (bool, error) {
-	_, err := c.objMgr.GetNetworkView(view)
+	// The network view is queried directly, since ObjectManager.GetNetworkView reports an empty result as an untyped error.
+	var views []ibclient.NetworkView
+	err := c.connector.GetObject(ibclient.NewEmptyNetworkView(), "", ibclient.NewQueryParams(false, map[string]string{"name": view}), &views)
 	if err != nil {
 		if isNotFound(err) {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
-	return true, nil
+	return len(views) > 0, nil
 }
 
 func (c *client) CheckDNSViewExists(view string) (bool, error) {
@@ -147,7 +245,7 @@ func (c *client) CheckDNSViewExists(view string) (bool, error) {
 		if isNotFound(err) {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
 	return true, nil
 }
@@ -158,7 +256,7 @@ func (c *client) CheckNetworkExists(view string, subnet netip.Prefix) (bool, err
 		if isNotFound(err) {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
 	return true, nil
 }
@@ -167,33 +265,38 @@ func (c *client) GetHostConfig() *HostConfig {
 	return &c.hc
 }
 
-func isNotFound(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.HasSuffix(err.Error(), "not found")
-}
-
-// tryParseWapiError tries to parse a wapi error as good as possible to extract the actual error message from the infoblox API response.
-// If parsing fails for any reason, the original error is returned.
-func tryParseWapiError(in error) error {
-	if in == nil {
-		return nil
-	}
-	raw := in.Error()
-	_, content, ok := strings.Cut(raw, "Contents:")
-	if !ok {
-		return in
-	}
-	type wapiErrorContent struct {
-		Error string `json:"Error"`
-		Code  string `json:"code"`
-		Text  string `json:"text"`
+// recordChange reports a change to the OnChange function and the audit log of the config, if any.
+// Failed changes are only written to the audit log.
+func (c *client) recordChange(change Change, err error) {
+	change.DryRun = c.dryRun
+	if c.audit.Log != nil {
+		record := AuditRecord{
+			Operation:  change.Operation,
+			ObjectType: change.ObjectType,
+			Ref:        change.Ref,
+			Name:       change.Name,
+			Addresses:  change.Addresses,
+			Instance:   c.hc.Host,
+			User:       c.auth.Username,
+			Claim:      c.audit.Claim,
+			Cluster:    c.audit.Cluster,
+			Pool:       c.audit.Pool,
+			Result:     AuditResultSuccess,
+		}
+		if c.auth.Source != nil {
+			record.User = ""
+		}
+		switch {
+		case err != nil:
+			record.Result = AuditResultFailure
+			record.Error = err.Error()
+		case c.dryRun:
+			record.Result = AuditResultDryRun
+		}
+		// The change has been made already, so failing to write the record must not fail the operation.
+		_ = c.audit.Log.Record(record)
 	}
-	var wapiErr wapiErrorContent
-	err := json.Unmarshal([]byte(content), &wapiErr)
-	if err != nil {
-		return in
+	if err == nil && c.onChange != nil {
+		c.onChange(change)
 	}
-	return fmt.Errorf("%s (%s)", wapiErr.Text, wapiErr.Code)
 }
@@ -5,6 +5,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
 )
 
 var _ = Describe("Infoblox Client", func() {
@@ -28,4 +29,33 @@ var _ = Describe("Infoblox Client", func() {
 			})
 		})
 	})
+
+	Context("network containers", func() {
+		const owner = "test-namespace/test-pool"
+		logger := klog.TODO()
+
+		It("allocates a network once and releases it", func() {
+			container := netip.MustParsePrefix(v4testIBNetwork.Cidr)
+			subnet, err := testClient.GetOrAllocateNetwork(testView, container, 28, owner, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.Contains(subnet.Addr())).To(BeTrue())
+			Expect(subnet.Bits()).To(Equal(28))
+
+			again, err := testClient.GetOrAllocateNetwork(testView, container, 28, owner, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).To(Equal(subnet))
+
+			Expect(testClient.ReleaseNetwork(testView, subnet, owner, logger)).To(Succeed())
+			exists, err := testClient.CheckNetworkExists(testView, subnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+
+		It("does not release networks owned by someone else", func() {
+			Expect(testClient.ReleaseNetwork(testView, v4subnet1, owner, logger)).To(Succeed())
+			exists, err := testClient.CheckNetworkExists(testView, v4subnet1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+	})
 })
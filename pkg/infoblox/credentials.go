@@ -0,0 +1,148 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTokenExchangeTTL is used to cache credentials from a token exchange endpoint that doesn't return a lease duration.
+	defaultTokenExchangeTTL = 5 * time.Minute
+	tokenExchangeTimeout    = 10 * time.Second
+)
+
+// CredentialSource provides the credentials used to authenticate against the WAPI.
+// It is queried for every request, so implementations can return rotated credentials without recreating the client.
+type CredentialSource interface {
+	// Credentials returns the current credentials. Only the Username, Password, ClientCert and ClientKey fields are used.
+	Credentials() (AuthConfig, error)
+}
+
+// credentialRefresher is implemented by credential sources that cache credentials and can discard them,
+// e.g. because the WAPI rejected them.
+type credentialRefresher interface {
+	refresh()
+}
+
+// NewFileCredentialSource returns a CredentialSource that reads the credentials from a directory,
+// e.g. a mounted Secret or files rendered by a Vault agent. The directory uses the same file names as the keys of a credentials secret.
+// The files are read for every request, so changes are picked up immediately.
+func NewFileCredentialSource(dir string) CredentialSource {
+	return &fileCredentialSource{dir: dir}
+}
+
+type fileCredentialSource struct {
+	dir string
+}
+
+func (s *fileCredentialSource) Credentials() (AuthConfig, error) {
+	data := map[string][]byte{}
+	for _, key := range []string{secretKeyUsername, secretKeyPassowrd, secretKeyClientCert, secretKeyClientKey} {
+		content, err := os.ReadFile(filepath.Join(s.dir, key))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return AuthConfig{}, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		data[key] = content
+	}
+	config, err := AuthConfigFromSecretData(data)
+	if err != nil {
+		return AuthConfig{}, fmt.Errorf("credentials in %s are invalid: %w", s.dir, err)
+	}
+	return config, nil
+}
+
+// NewTokenExchangeCredentialSource returns a CredentialSource that fetches the credentials from a local HTTP endpoint,
+// e.g. the API proxy of a Vault agent sidecar. The endpoint must respond with a JSON object containing a username and password,
+// either at the top level or below data, like Vault does for KV and dynamic secrets.
+// The credentials are cached for two thirds of the returned lease_duration, or five minutes if there is none.
+// Redirects are not followed, so the endpoint can't forward the request to another host.
+func NewTokenExchangeCredentialSource(url string) CredentialSource {
+	return &tokenExchangeCredentialSource{
+		url: url,
+		client: &http.Client{
+			Timeout: tokenExchangeTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+type tokenExchangeCredentialSource struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	config  AuthConfig
+	expires time.Time
+}
+
+// tokenExchangeResponse is the response of a token exchange endpoint.
+// Vault returns the secret in data for KV v1 and dynamic secrets, and in data.data for KV v2.
+type tokenExchangeResponse struct {
+	Username      string                 `json:"username"`
+	Password      string                 `json:"password"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          *tokenExchangeResponse `json:"data"`
+}
+
+func (s *tokenExchangeCredentialSource) Credentials() (AuthConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.now().Before(s.expires) {
+		return s.config, nil
+	}
+
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return AuthConfig{}, fmt.Errorf("failed to exchange token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return AuthConfig{}, fmt.Errorf("failed to exchange token: unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AuthConfig{}, fmt.Errorf("failed to read token exchange response: %w", err)
+	}
+	response := &tokenExchangeResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return AuthConfig{}, fmt.Errorf("failed to parse token exchange response: %w", err)
+	}
+
+	ttl := defaultTokenExchangeTTL
+	if response.LeaseDuration > 0 {
+		ttl = time.Duration(response.LeaseDuration) * time.Second * 2 / 3
+	}
+	for response.Username == "" && response.Data != nil {
+		response = response.Data
+	}
+	if response.Username == "" || response.Password == "" {
+		return AuthConfig{}, errors.New("token exchange response does not contain a username and password")
+	}
+
+	s.config = AuthConfig{Username: response.Username, Password: response.Password}
+	s.expires = s.now().Add(ttl)
+	return s.config, nil
+}
+
+func (s *tokenExchangeCredentialSource) refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expires = time.Time{}
+}
@@ -0,0 +1,111 @@
+package infoblox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func writeCredentials(t *testing.T, dir, username, password string) {
+	t.Helper()
+	for name, content := range map[string]string{"username": username, "password": password} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileCredentialSource(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	source := NewFileCredentialSource(dir)
+	_, err := source.Credentials()
+	g.Expect(err).To(MatchError(ContainSubstring("no usable pair of credentials")))
+
+	writeCredentials(t, dir, "admin", "secret")
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "secret"}))
+
+	writeCredentials(t, dir, "admin", "rotated")
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "rotated"}), "should pick up changed files")
+}
+
+func TestTokenExchangeCredentialSource(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests atomic.Int32
+	response := `{"data":{"data":{"username":"admin","password":"secret"},"metadata":{"version":1}},"lease_duration":0}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	source := NewTokenExchangeCredentialSource(server.URL).(*tokenExchangeCredentialSource)
+	source.now = func() time.Time { return now }
+
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "secret"}), "should support Vault KV v2 responses")
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "secret"}))
+	g.Expect(requests.Load()).To(BeEquivalentTo(1), "should cache the credentials")
+
+	response = `{"data":{"username":"v-admin","password":"dynamic"},"lease_duration":60}`
+	now = now.Add(defaultTokenExchangeTTL)
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "v-admin", Password: "dynamic"}), "should support Vault dynamic secrets")
+
+	response = `{"username":"admin","password":"rotated"}`
+	now = now.Add(30 * time.Second)
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "v-admin", Password: "dynamic"}))
+	now = now.Add(10 * time.Second)
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "rotated"}), "should refresh after two thirds of the lease")
+
+	response = `{"username":"admin","password":"refreshed"}`
+	source.refresh()
+	g.Expect(source.Credentials()).To(Equal(AuthConfig{Username: "admin", Password: "refreshed"}))
+
+	response = `{"data":{}}`
+	source.refresh()
+	_, err := source.Credentials()
+	g.Expect(err).To(MatchError(ContainSubstring("does not contain a username and password")))
+}
+
+func TestTokenExchangeCredentialSourceDoesNotFollowRedirects(t *testing.T) {
+	g := NewWithT(t)
+
+	var redirected atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		redirected.Add(1)
+		_, _ = w.Write([]byte(`{"username":"admin","password":"secret"}`))
+	}))
+	t.Cleanup(target.Close)
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(server.Close)
+
+	_, err := NewTokenExchangeCredentialSource(server.URL).Credentials()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(redirected.Load()).To(BeZero())
+}
+
+func TestClientUsesCredentialSource(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	dir := t.TempDir()
+	config.AuthConfig = AuthConfig{Source: NewFileCredentialSource(dir)}
+
+	writeCredentials(t, dir, "admin", "wrong")
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ContainSubstring("401")))
+
+	writeCredentials(t, dir, "admin", "secret")
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue(), "should use the rotated credentials without recreating the client")
+}
@@ -0,0 +1,95 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+)
+
+// dryRunRefPrefix is the prefix of the references returned for objects created in dry-run mode, e.g. record:host/dry-run:1.
+const dryRunRefPrefix = "dry-run:"
+
+// dryRunConnector reads objects from Infoblox but only logs the objects it would create, update or delete.
+// Objects it would create are returned with a synthetic reference, so they can be read and changed again by the same client.
+type dryRunConnector struct {
+	ibclient.IBConnector
+	logger logr.Logger
+
+	mu      sync.Mutex
+	created map[string]json.RawMessage
+	next    int
+}
+
+func newDryRunConnector(connector ibclient.IBConnector, logger logr.Logger) *dryRunConnector {
+	return &dryRunConnector{IBConnector: connector, logger: logger, created: map[string]json.RawMessage{}}
+}
+
+// CreateObject logs the object and returns a synthetic reference.
+func (c *dryRunConnector) CreateObject(obj ibclient.IBObject) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next++
+	ref := fmt.Sprintf("%s/%s%d", obj.ObjectType(), dryRunRefPrefix, c.next)
+	data, err := withRef(obj, ref)
+	if err != nil {
+		return "", err
+	}
+	c.created[ref] = data
+	c.logger.Info("Would create object", "objectType", obj.ObjectType(), "object", string(data))
+	return ref, nil
+}
+
+// GetObject reads objects created in dry-run mode from memory and all other objects from Infoblox.
+func (c *dryRunConnector) GetObject(obj ibclient.IBObject, ref string, queryParams *ibclient.QueryParams, res interface{}) error {
+	c.mu.Lock()
+	data, ok := c.created[ref]
+	c.mu.Unlock()
+	if !ok {
+		return c.IBConnector.GetObject(obj, ref, queryParams, res)
+	}
+	return json.Unmarshal(data, res)
+}
+
+// UpdateObject logs the object and returns its reference.
+func (c *dryRunConnector) UpdateObject(obj ibclient.IBObject, ref string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := withRef(obj, ref)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := c.created[ref]; ok {
+		c.created[ref] = data
+	}
+	c.logger.Info("Would update object", "ref", ref, "object", string(data))
+	return ref, nil
+}
+
+// DeleteObject logs the reference of the object and returns it.
+func (c *dryRunConnector) DeleteObject(ref string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.created, ref)
+	c.logger.Info("Would delete object", "ref", ref)
+	return ref, nil
+}
+
+// withRef returns the JSON representation of an object with the given reference.
+func withRef(obj ibclient.IBObject, ref string) (json.RawMessage, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", obj.ObjectType(), err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", obj.ObjectType(), err)
+	}
+	fields["_ref"], _ = json.Marshal(ref)
+	return json.Marshal(fields)
+}
@@ -0,0 +1,76 @@
+package infoblox
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+)
+
+func TestDryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	const ref = "record:host/ZG5zLmhvc3QkLl9kZWZhdWx0:existing.example.com/default"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wapi/v2.12/record:host", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s request in dry-run mode", r.Method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("/wapi/v2.12/record:host/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s request in dry-run mode", r.Method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"_ref":"` + ref + `","name":"existing.example.com","ipv4addrs":[{"ipv4addr":"10.0.0.3"}]}`))
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	var changes []Change
+	config := testConfig(t, server)
+	config.DryRun = true
+	config.Audit = AuditContext{Log: NewAuditLog(&buf)}
+	config.OnChange = func(change Change) { changes = append(changes, change) }
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	subnet := netip.MustParsePrefix("10.0.0.0/24")
+	_, err = c.GetOrAllocateAddress("default", "default", subnet, "new.example.com", "", klog.TODO())
+	g.Expect(err).To(MatchError(ErrDryRun))
+	g.Expect(err).To(MatchError(ContainSubstring(`would allocate the next available address in subnet 10.0.0.0/24 for host record "new.example.com"`)))
+
+	g.Expect(c.ReleaseAddressByRef(ref, netip.MustParseAddr("10.0.0.3"), klog.TODO())).To(Succeed())
+
+	g.Expect(changes).To(HaveLen(2))
+	g.Expect(changes[0]).To(SatisfyAll(
+		HaveField("Operation", ChangeCreate),
+		HaveField("Name", "new.example.com"),
+		HaveField("Ref", "record:host/dry-run:1"),
+		HaveField("DryRun", true),
+	))
+	g.Expect(changes[1]).To(SatisfyAll(
+		HaveField("Operation", ChangeDelete),
+		HaveField("Ref", ref),
+		HaveField("DryRun", true),
+	))
+
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record AuditRecord
+		g.Expect(decoder.Decode(&record)).To(Succeed())
+		g.Expect(record.Result).To(Equal(AuditResultDryRun))
+	}
+}
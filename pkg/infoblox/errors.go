@@ -0,0 +1,141 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+)
+
+// Errors returned by the client are classified into one of these kinds, which can be checked with errors.Is.
+var (
+	// ErrNotFound indicates that a WAPI object does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict indicates that an object conflicts with an existing one, e.g. because it was created concurrently.
+	ErrConflict = errors.New("conflict")
+	// ErrAuthFailed indicates that the credentials were rejected or the user is not allowed to perform the request.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrRangeExhausted indicates that no address or network is available in a subnet or network container.
+	ErrRangeExhausted = errors.New("range exhausted")
+	// ErrTransient indicates an error that is expected to resolve without changes, e.g. an unreachable or overloaded grid.
+	ErrTransient = errors.New("transient error")
+	// ErrValidation indicates that the WAPI rejected a request as invalid.
+	ErrValidation = errors.New("validation failed")
+	// ErrDryRun indicates that the result of a change is unknown because the change has not been sent to Infoblox in dry-run mode,
+	// e.g. the next available address of a subnet.
+	ErrDryRun = errors.New("dry run")
+)
+
+const (
+	wapiCodeConflict = "Client.Ibap.Data.Conflict"
+	wapiCodeNotFound = "Client.Ibap.Data.NotFound"
+	wapiCodeAuth     = "Client.Ibap.Auth"
+)
+
+// Error is an error returned by the WAPI or while sending a request to it.
+// It wraps one of the error kinds, e.g. ErrNotFound, and preserves the HTTP status and WAPI error code.
+type Error struct {
+	// Kind is the error kind, e.g. ErrNotFound.
+	Kind error
+	// StatusCode is the HTTP status code of the response, or 0 if no response was received.
+	StatusCode int
+	// Code is the WAPI error code, e.g. Client.Ibap.Data.Conflict. It is empty if the response did not contain one.
+	Code string
+	// Text is the error message returned by the WAPI.
+	Text string
+
+	// err is the underlying error if no WAPI response was received.
+	err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.err != nil:
+		return e.err.Error()
+	case e.Code != "":
+		return fmt.Sprintf("%s (%s)", e.Text, e.Code)
+	case e.Text != "":
+		return fmt.Sprintf("WAPI request error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Text)
+	default:
+		return fmt.Sprintf("WAPI request error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+}
+
+// Unwrap returns the error kind and the underlying error, if any.
+func (e *Error) Unwrap() []error {
+	if e.err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.err}
+}
+
+// newResponseError creates an Error from an unsuccessful WAPI response.
+func newResponseError(statusCode int, body []byte) *Error {
+	var content struct {
+		Code string `json:"code"`
+		Text string `json:"text"`
+	}
+	e := &Error{StatusCode: statusCode}
+	if err := json.Unmarshal(body, &content); err == nil && content.Text != "" {
+		e.Code = content.Code
+		e.Text = content.Text
+	} else {
+		e.Text = strings.TrimSpace(string(body))
+	}
+	e.Kind = errorKind(e.StatusCode, e.Code, e.Text)
+	return e
+}
+
+// errorKind classifies an unsuccessful WAPI response.
+func errorKind(statusCode int, code, text string) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || strings.HasPrefix(code, wapiCodeAuth):
+		return ErrAuthFailed
+	case statusCode == http.StatusNotFound || code == wapiCodeNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return ErrTransient
+	case code == wapiCodeConflict && isRangeExhausted(text):
+		return ErrRangeExhausted
+	case code == wapiCodeConflict:
+		return ErrConflict
+	default:
+		return ErrValidation
+	}
+}
+
+// isRangeExhausted returns whether a conflict was caused by a next available IP or network function,
+// e.g. "Cannot find 1 available IP address(es) in this network".
+func isRangeExhausted(text string) bool {
+	return strings.HasPrefix(text, "Cannot find") && strings.Contains(text, "available")
+}
+
+// classifyError returns an Error for errors returned by ibclient, so they can be checked with errors.Is.
+// Errors that are already classified or can't be classified are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var wapiErr *Error
+	if errors.As(err, &wapiErr) {
+		return err
+	}
+	var notFound *ibclient.NotFoundError
+	if errors.As(err, &notFound) {
+		return &Error{Kind: ErrNotFound, err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &Error{Kind: ErrTransient, err: err}
+	}
+	return err
+}
+
+// isNotFound returns whether an error indicates that a WAPI object does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(classifyError(err), ErrNotFound)
+}
@@ -0,0 +1,111 @@
+package infoblox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestNewResponseError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		kind       error
+		message    string
+	}{
+		{
+			name:       "exhausted subnet",
+			statusCode: http.StatusBadRequest,
+			body:       `{"Error": "AdmConDataError: None (IBDataConflictError: IB.Data.Conflict:Cannot find 1 available IP address(es) in this network)", "code": "Client.Ibap.Data.Conflict", "text": "Cannot find 1 available IP address(es) in this network"}`,
+			kind:       ErrRangeExhausted,
+			message:    "Cannot find 1 available IP address(es) in this network (Client.Ibap.Data.Conflict)",
+		},
+		{
+			name:       "duplicate object",
+			statusCode: http.StatusBadRequest,
+			body:       `{"Error": "AdmConDataError: None (IBDataConflictError: IB.Data.Conflict:The record 'host.example.com' already exists.)", "code": "Client.Ibap.Data.Conflict", "text": "The record 'host.example.com' already exists."}`,
+			kind:       ErrConflict,
+		},
+		{
+			name:       "invalid argument",
+			statusCode: http.StatusBadRequest,
+			body:       `{"Error": "AdmConProtoError: Invalid value for network: \"10.0.0.0/33\"", "code": "Client.Ibap.Proto", "text": "Invalid value for network: \"10.0.0.0/33\""}`,
+			kind:       ErrValidation,
+		},
+		{
+			name:       "missing object",
+			statusCode: http.StatusNotFound,
+			body:       `{"Error": "AdmConDataNotFoundError: Reference record:host/ZG5z does not exist", "code": "Client.Ibap.Data.NotFound", "text": "Reference record:host/ZG5z does not exist"}`,
+			kind:       ErrNotFound,
+		},
+		{
+			name:       "rejected credentials",
+			statusCode: http.StatusUnauthorized,
+			kind:       ErrAuthFailed,
+			message:    "WAPI request error: 401 Unauthorized",
+		},
+		{
+			name:       "throttled request",
+			statusCode: http.StatusTooManyRequests,
+			body:       "slow down",
+			kind:       ErrTransient,
+			message:    "WAPI request error: 429 Too Many Requests: slow down",
+		},
+		{
+			name:       "unavailable grid",
+			statusCode: http.StatusServiceUnavailable,
+			kind:       ErrTransient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := newResponseError(tt.statusCode, []byte(tt.body))
+			g.Expect(err).To(MatchError(tt.kind))
+			g.Expect(err.StatusCode).To(Equal(tt.statusCode))
+			if tt.message != "" {
+				g.Expect(err.Error()).To(Equal(tt.message))
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(classifyError(nil)).To(Succeed())
+	g.Expect(classifyError(ibclient.NewNotFoundError("requested object not found"))).To(MatchError(ErrNotFound))
+	g.Expect(classifyError(fmt.Errorf("failed to get host record: %w", ibclient.NewNotFoundError("host record not found")))).To(MatchError(ErrNotFound))
+	g.Expect(classifyError(errors.New("network view 'test' not found"))).NotTo(MatchError(ErrNotFound), "errors are not classified by their message")
+	g.Expect(classifyError(&net.OpError{Op: "dial", Err: errors.New("connection refused")})).To(MatchError(ErrTransient))
+
+	err := errors.New("something else")
+	g.Expect(classifyError(err)).To(BeIdenticalTo(err))
+
+	var wapiErr *Error
+	g.Expect(errors.As(classifyError(newResponseError(http.StatusBadRequest, []byte(`{"code":"Client.Ibap.Data.Conflict","text":"The record already exists."}`))), &wapiErr)).To(BeTrue())
+	g.Expect(wapiErr.Code).To(Equal("Client.Ibap.Data.Conflict"), "should preserve the WAPI code")
+}
+
+func TestClientReturnsTypedErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	config.Password = "wrong"
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ErrAuthFailed))
+
+	server.Close()
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ErrTransient))
+}
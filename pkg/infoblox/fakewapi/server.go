@@ -0,0 +1,634 @@
+// Package fakewapi implements an in-memory stand-in for the Infoblox WAPI, so the provider can be tested against real HTTP
+// without a grid. It supports network views, DNS views, networks, network containers, host records and fixed addresses,
+// including the next available IP and network functions and searches by extensible attributes.
+package fakewapi
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default names of the grid, network view and DNS view of a new server.
+const (
+	GridName           = "Infoblox"
+	DefaultNetworkView = "default"
+	DefaultDNSView     = "default"
+	// NIOSVersion is the version of NIOS reported by the upgrade status of the grid.
+	NIOSVersion = "9.0.3-fakewapi"
+)
+
+// SupportedVersions are the WAPI versions reported by the schema of the server. Requests are accepted for any version.
+var SupportedVersions = []string{"2.5", "2.12", "2.12.3"}
+
+// supportedObjects are the object types reported by the schema of the server.
+var supportedObjects = []string{
+	"extensibleattributedef", "fixedaddress", "grid", "ipv6fixedaddress", "ipv6network", "ipv6networkcontainer",
+	"network", "networkcontainer", "networkview", "record:host", "upgradestatus", "view",
+}
+
+// Object is a WAPI object as returned by the server, e.g. {"_ref": "...", "name": "host.example.com"}.
+type Object map[string]any
+
+// Server is an in-memory WAPI stand-in. It is safe for concurrent use.
+type Server struct {
+	httpServer *httptest.Server
+	username   string
+	password   string
+
+	mu      sync.Mutex
+	objects map[string]Object
+	nextID  int
+}
+
+// NewServer starts a server that accepts requests with the given credentials, or any request if username is empty.
+// The server contains the default network view and DNS view.
+func NewServer(username, password string) *Server {
+	s := &Server{username: username, password: password, objects: map[string]Object{}}
+	s.AddNetworkView(DefaultNetworkView)
+	s.AddDNSView(DefaultDNSView)
+	s.httpServer = httptest.NewTLSServer(s)
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// URL returns the base URL of the server, e.g. https://127.0.0.1:12345.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// Host returns the host of the server.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port of the server.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	return port
+}
+
+// CACertificate returns the PEM encoded certificate of the server, which clients need to trust.
+func (s *Server) CACertificate() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.httpServer.Certificate().Raw})
+}
+
+// AddNetworkView adds a network view.
+func (s *Server) AddNetworkView(name string) string {
+	return s.add("networkview", name, Object{"name": name, "extattrs": map[string]any{}})
+}
+
+// AddDNSView adds a DNS view.
+func (s *Server) AddDNSView(name string) string {
+	return s.add("view", name, Object{"name": name, "extattrs": map[string]any{}})
+}
+
+// AddNetwork adds an IPv4 or IPv6 network to a network view and returns its reference.
+func (s *Server) AddNetwork(view string, network netip.Prefix, extattrs map[string]string) string {
+	objectType := "network"
+	if network.Addr().Is6() {
+		objectType = "ipv6network"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(objectType, network.String()+"/"+view, Object{
+		"network":           network.String(),
+		"network_view":      view,
+		"network_container": s.networkContainer(objectType, view, network),
+		"extattrs":          toExtAttrs(extattrs),
+	})
+}
+
+// AddNetworkContainer adds an IPv4 or IPv6 network container to a network view and returns its reference.
+func (s *Server) AddNetworkContainer(view string, container netip.Prefix) string {
+	objectType := "networkcontainer"
+	if container.Addr().Is6() {
+		objectType = "ipv6networkcontainer"
+	}
+	return s.add(objectType, container.String()+"/"+view, Object{"network": container.String(), "network_view": view, "extattrs": map[string]any{}})
+}
+
+// Objects returns copies of the objects of the given type, e.g. record:host.
+func (s *Server) Objects(objectType string) []Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.find(objectType, nil)
+}
+
+// HostRecordAddresses returns the IPv4 and IPv6 addresses of the host records with the given name in all views.
+func (s *Server) HostRecordAddresses(name string) []string {
+	var addresses []string
+	for _, hr := range s.Objects("record:host") {
+		if hr["name"] == name {
+			addresses = append(addresses, hostRecordAddresses(hr)...)
+		}
+	}
+	return addresses
+}
+
+func toExtAttrs(extattrs map[string]string) map[string]any {
+	ea := map[string]any{}
+	for k, v := range extattrs {
+		ea[k] = map[string]any{"value": v}
+	}
+	return ea
+}
+
+// add stores an object and returns its reference. The caller must not hold the lock.
+func (s *Server) add(objectType, name string, object Object) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(objectType, name, object)
+}
+
+// store assigns a reference to an object and stores it.
+func (s *Server) store(objectType, name string, object Object) string {
+	s.nextID++
+	id := strings.TrimRight(base32.StdEncoding.EncodeToString([]byte(fmt.Sprintf("fakewapi.%s$%d", objectType, s.nextID))), "=")
+	// Like the WAPI, colons and spaces in the name part are escaped, e.g. ipv6network/ID:fd00%3A%3A/64/default.
+	ref := objectType + "/" + id + ":" + strings.NewReplacer(":", "%3A", " ", "%20").Replace(name)
+	object["_ref"] = ref
+	s.objects[id] = object
+	return ref
+}
+
+// refID returns the ID of a reference, which identifies the object independently of the escaping of the rest of the reference.
+func refID(ref string) (string, string) {
+	objectType, rest, _ := strings.Cut(ref, "/")
+	id, _, _ := strings.Cut(rest, ":")
+	return objectType, id
+}
+
+// find returns copies of the objects of a type matching the given search parameters.
+func (s *Server) find(objectType string, query map[string][]string) []Object {
+	result := []Object{}
+	for _, object := range s.objects {
+		if t, _ := refID(object["_ref"].(string)); t != objectType || !matches(object, query) {
+			continue
+		}
+		result = append(result, copyObject(object))
+	}
+	slices.SortFunc(result, func(a, b Object) int { return strings.Compare(a["_ref"].(string), b["_ref"].(string)) })
+	return result
+}
+
+// matches returns whether an object matches all search parameters. Parameters starting with _ are options, not fields.
+// Parameters starting with * search extensible attributes.
+func matches(object Object, query map[string][]string) bool {
+	for key, values := range query {
+		if strings.HasPrefix(key, "_") || len(values) == 0 {
+			continue
+		}
+		if attribute, ok := strings.CutPrefix(key, "*"); ok {
+			ea, _ := object["extattrs"].(map[string]any)
+			value, _ := ea[attribute].(map[string]any)
+			if value == nil || fmt.Sprint(value["value"]) != values[0] {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(object[key]) != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyObject(object Object) Object {
+	data, _ := json.Marshal(object)
+	var c Object
+	_ = json.Unmarshal(data, &c)
+	return c
+}
+
+// wapiPath matches request paths, optionally with a path prefix, e.g. /wapi/v2.12/record:host.
+var wapiPath = regexp.MustCompile(`^.*/wapi/v[0-9.]+/(.*)$`)
+
+// ServeHTTP handles a WAPI request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.username != "" {
+		if user, password, ok := r.BasicAuth(); !ok || user != s.username || password != s.password {
+			writeError(w, http.StatusUnauthorized, "Client.Ibap.Auth", "Authorization Required")
+			return
+		}
+	}
+
+	m := wapiPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "Client.Ibap.Proto", "Unknown path "+r.URL.Path)
+		return
+	}
+	target := m[1]
+	if target == "" {
+		if _, ok := r.URL.Query()["_schema"]; ok && r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, map[string]any{"supported_objects": supportedObjects, "supported_versions": SupportedVersions})
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", "Missing object type")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objectType, _, isRef := strings.Cut(target, "/")
+	switch {
+	case r.Method == http.MethodGet && objectType == "grid":
+		writeJSON(w, http.StatusOK, []Object{{"_ref": "grid/" + GridName + ":" + GridName, "name": GridName}})
+	case r.Method == http.MethodGet && objectType == "upgradestatus":
+		writeJSON(w, http.StatusOK, []Object{{"_ref": "upgradestatus/" + GridName + ":" + GridName, "current_version": NIOSVersion}})
+	case r.Method == http.MethodGet && isRef:
+		object, ok := s.get(target)
+		if !ok {
+			writeNotFound(w, target)
+			return
+		}
+		writeJSON(w, http.StatusOK, copyObject(object))
+	case r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.find(objectType, r.URL.Query()))
+	case r.Method == http.MethodPost && !isRef:
+		object, err := readObject(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+			return
+		}
+		ref, werr := s.create(objectType, object)
+		if werr != nil {
+			writeError(w, werr.status, werr.code, werr.text)
+			return
+		}
+		writeJSON(w, http.StatusCreated, ref)
+	case r.Method == http.MethodPut && isRef:
+		existing, ok := s.get(target)
+		if !ok {
+			writeNotFound(w, target)
+			return
+		}
+		update, err := readObject(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+			return
+		}
+		if werr := s.update(objectType, existing, update); werr != nil {
+			writeError(w, werr.status, werr.code, werr.text)
+			return
+		}
+		writeJSON(w, http.StatusOK, existing["_ref"])
+	case r.Method == http.MethodDelete && isRef:
+		object, ok := s.get(target)
+		if !ok {
+			writeNotFound(w, target)
+			return
+		}
+		_, id := refID(object["_ref"].(string))
+		delete(s.objects, id)
+		writeJSON(w, http.StatusOK, object["_ref"])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Client.Ibap.Proto", "Unsupported request "+r.Method+" "+target)
+	}
+}
+
+func (s *Server) get(ref string) (Object, bool) {
+	objectType, id := refID(ref)
+	object, ok := s.objects[id]
+	if !ok {
+		return nil, false
+	}
+	if t, _ := refID(object["_ref"].(string)); t != objectType {
+		return nil, false
+	}
+	return object, true
+}
+
+func readObject(body io.Reader) (Object, error) {
+	var object Object
+	if err := json.NewDecoder(body).Decode(&object); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return object, nil
+}
+
+// wapiError is an error response of the WAPI.
+type wapiError struct {
+	status int
+	code   string
+	text   string
+}
+
+func validationError(format string, args ...any) *wapiError {
+	return &wapiError{status: http.StatusBadRequest, code: "Client.Ibap.Data", text: fmt.Sprintf(format, args...)}
+}
+
+func conflictError(format string, args ...any) *wapiError {
+	return &wapiError{status: http.StatusBadRequest, code: "Client.Ibap.Data.Conflict", text: fmt.Sprintf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// Clients compare empty results with [] literally, so no trailing newline is written.
+	data, _ := json.Marshal(body)
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, text string) {
+	writeJSON(w, status, map[string]string{"Error": code + ": " + text, "code": code, "text": text})
+}
+
+func writeNotFound(w http.ResponseWriter, ref string) {
+	writeError(w, http.StatusNotFound, "Client.Ibap.Data.NotFound", "Reference "+ref+" not found")
+}
+
+// create validates an object, resolves next available functions and stores it.
+func (s *Server) create(objectType string, object Object) (string, *wapiError) {
+	view := stringField(object, "network_view", DefaultNetworkView)
+	object["network_view"] = view
+	if len(s.find("networkview", map[string][]string{"name": {view}})) == 0 {
+		return "", validationError("Network view %s not found", view)
+	}
+	if _, ok := object["extattrs"]; !ok {
+		object["extattrs"] = map[string]any{}
+	}
+
+	switch objectType {
+	case "record:host":
+		name := stringField(object, "name", "")
+		if name == "" {
+			return "", validationError("Field name is required")
+		}
+		if configureForDNS, _ := object["configure_for_dns"].(bool); configureForDNS {
+			dnsView := stringField(object, "view", DefaultDNSView)
+			if len(s.find("view", map[string][]string{"name": {dnsView}})) == 0 {
+				return "", validationError("DNS view %s not found", dnsView)
+			}
+			object["view"] = dnsView
+		}
+		if len(s.find(objectType, map[string][]string{"name": {name}, "network_view": {view}})) > 0 {
+			return "", conflictError("The record '%s' already exists.", name)
+		}
+		if werr := s.resolveHostAddresses(object, view); werr != nil {
+			return "", werr
+		}
+		return s.store(objectType, name+"/"+stringField(object, "view", " "), object), nil
+	case "network", "ipv6network":
+		network, werr := s.resolveNetwork(objectType, object, view)
+		if werr != nil {
+			return "", werr
+		}
+		object["network"] = network.String()
+		object["network_container"] = s.networkContainer(objectType, view, network)
+		return s.store(objectType, network.String()+"/"+view, object), nil
+	case "fixedaddress", "ipv6fixedaddress":
+		field := "ipv4addr"
+		if objectType == "ipv6fixedaddress" {
+			field = "ipv6addr"
+		}
+		addr, err := netip.ParseAddr(stringField(object, field, ""))
+		if err != nil {
+			return "", validationError("Invalid value for %s", field)
+		}
+		if s.addressInUse(view, addr) {
+			return "", conflictError("The IP address %s is already in use.", addr)
+		}
+		return s.store(objectType, addr.String()+"/"+view, object), nil
+	default:
+		return "", validationError("Creating %s objects is not supported", objectType)
+	}
+}
+
+// update replaces the fields of an existing object. The network view of an object can't be changed, so empty values are ignored.
+func (s *Server) update(objectType string, existing, update Object) *wapiError {
+	view := stringField(existing, "network_view", DefaultNetworkView)
+	for _, field := range []string{"network_view", "zone"} {
+		if v, ok := update[field]; ok && v == "" {
+			delete(update, field)
+		}
+	}
+	if v, ok := update["network_view"]; ok && v != view {
+		return validationError("Field network_view cannot be changed")
+	}
+	if objectType == "record:host" {
+		if werr := s.resolveHostAddresses(update, view); werr != nil {
+			return werr
+		}
+	}
+	for k, v := range update {
+		existing[k] = v
+	}
+	return nil
+}
+
+// resolveHostAddresses allocates the next available address for host record addresses using func:nextavailableip.
+func (s *Server) resolveHostAddresses(object Object, view string) *wapiError {
+	name := stringField(object, "name", "")
+	for _, field := range []string{"ipv4addrs", "ipv6addrs"} {
+		addrField := strings.TrimSuffix(field, "s")
+		entries, _ := object[field].([]any)
+		for _, e := range entries {
+			entry, ok := e.(map[string]any)
+			if !ok {
+				return validationError("Invalid value for %s", field)
+			}
+			value := stringField(entry, addrField, "")
+			if args, ok := strings.CutPrefix(value, "func:nextavailableip:"); ok {
+				addr, werr := s.nextAvailableIP(args, view)
+				if werr != nil {
+					return werr
+				}
+				entry[addrField] = addr.String()
+			} else if _, err := netip.ParseAddr(value); err != nil {
+				return validationError("Invalid value for %s: %s", addrField, value)
+			}
+			if name != "" {
+				entry["host"] = name
+			}
+		}
+		if entries == nil {
+			object[field] = []any{}
+		}
+	}
+	return nil
+}
+
+// nextAvailableIP returns the first unused address of a network, e.g. for the arguments 10.0.0.0/24,default.
+// The network address and the IPv4 broadcast address are never returned.
+func (s *Server) nextAvailableIP(args, view string) (netip.Addr, *wapiError) {
+	cidr, argView, _ := strings.Cut(args, ",")
+	if argView != "" {
+		view = argView
+	}
+	network, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Addr{}, validationError("Invalid network %s", cidr)
+	}
+	objectType := "network"
+	if network.Addr().Is6() {
+		objectType = "ipv6network"
+	}
+	if len(s.find(objectType, map[string][]string{"network": {network.String()}, "network_view": {view}})) == 0 {
+		return netip.Addr{}, validationError("Cannot find network %s in network view %s", network, view)
+	}
+
+	// Large IPv6 networks are only searched partially.
+	const maxCandidates = 1 << 16
+	addr := network.Masked().Addr().Next()
+	for i := 0; i < maxCandidates && addr.IsValid() && network.Contains(addr); i++ {
+		next := addr.Next()
+		isBroadcast := addr.Is4() && !network.Contains(next)
+		if !isBroadcast && !s.addressInUse(view, addr) {
+			return addr, nil
+		}
+		addr = next
+	}
+	return netip.Addr{}, conflictError("Cannot find 1 available IP address(es) in this network")
+}
+
+// addressInUse returns whether an address is used by a host record or fixed address in a network view.
+func (s *Server) addressInUse(view string, addr netip.Addr) bool {
+	query := map[string][]string{"network_view": {view}}
+	for _, hr := range s.find("record:host", query) {
+		if slices.Contains(hostRecordAddresses(hr), addr.String()) {
+			return true
+		}
+	}
+	for _, objectType := range []string{"fixedaddress", "ipv6fixedaddress"} {
+		for _, fa := range s.find(objectType, query) {
+			if fa["ipv4addr"] == addr.String() || fa["ipv6addr"] == addr.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveNetwork returns the network to create, allocating the next available network of a container
+// for func:nextavailablenetwork:<container>,<view>,<prefix length>.
+func (s *Server) resolveNetwork(objectType string, object Object, view string) (netip.Prefix, *wapiError) {
+	value := stringField(object, "network", "")
+	args, isFunc := strings.CutPrefix(value, "func:nextavailablenetwork:")
+	if !isFunc {
+		network, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, validationError("Invalid network %s", value)
+		}
+		if s.networkOverlaps(objectType, view, network) {
+			return netip.Prefix{}, conflictError("The network %s overlaps with an existing network.", network)
+		}
+		return network, nil
+	}
+
+	parts := strings.Split(args, ",")
+	if len(parts) != 3 {
+		return netip.Prefix{}, validationError("Invalid arguments for nextavailablenetwork: %s", args)
+	}
+	container, err := netip.ParsePrefix(parts[0])
+	prefixLength, perr := strconv.Atoi(parts[2])
+	if err != nil || perr != nil || prefixLength < container.Bits() || prefixLength > container.Addr().BitLen() {
+		return netip.Prefix{}, validationError("Invalid arguments for nextavailablenetwork: %s", args)
+	}
+	view = cmpOr(parts[1], view)
+	if len(s.find(objectType+"container", map[string][]string{"network": {container.String()}, "network_view": {view}})) == 0 {
+		return netip.Prefix{}, validationError("Cannot find network container %s in network view %s", container, view)
+	}
+
+	const maxCandidates = 1 << 16
+	candidate := netip.PrefixFrom(container.Masked().Addr(), prefixLength)
+	for i := 0; i < maxCandidates && container.Contains(candidate.Addr()); i++ {
+		if !s.networkOverlaps(objectType, view, candidate) {
+			return candidate, nil
+		}
+		next, ok := nextPrefix(candidate)
+		if !ok {
+			break
+		}
+		candidate = next
+	}
+	return netip.Prefix{}, conflictError("Cannot find 1 available network(s) in this network container")
+}
+
+// networkContainer returns the smallest network container of a network view containing a network, or / if there is none.
+func (s *Server) networkContainer(objectType, view string, network netip.Prefix) string {
+	container := "/"
+	bits := -1
+	for _, c := range s.find(objectType+"container", map[string][]string{"network_view": {view}}) {
+		if p, err := netip.ParsePrefix(stringField(c, "network", "")); err == nil && p.Bits() > bits && p.Bits() <= network.Bits() && p.Contains(network.Addr()) {
+			container, bits = p.String(), p.Bits()
+		}
+	}
+	return container
+}
+
+// networkOverlaps returns whether a network overlaps with an existing network in a network view.
+func (s *Server) networkOverlaps(objectType, view string, network netip.Prefix) bool {
+	for _, existing := range s.find(objectType, map[string][]string{"network_view": {view}}) {
+		if p, err := netip.ParsePrefix(stringField(existing, "network", "")); err == nil && p.Overlaps(network) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPrefix returns the prefix of the same length following p.
+func nextPrefix(p netip.Prefix) (netip.Prefix, bool) {
+	b := p.Addr().AsSlice()
+	// Add 1 at the last bit of the prefix.
+	bit := p.Bits() - 1
+	for i := bit / 8; i >= 0; i-- {
+		inc := byte(1)
+		if i == bit/8 {
+			inc = 1 << (7 - bit%8)
+		}
+		sum := b[i] + inc
+		carry := sum < b[i]
+		b[i] = sum
+		if !carry {
+			addr, _ := netip.AddrFromSlice(b)
+			return netip.PrefixFrom(addr, p.Bits()), true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// hostRecordAddresses returns the IPv4 and IPv6 addresses of a host record.
+func hostRecordAddresses(hr Object) []string {
+	var addresses []string
+	for _, field := range []string{"ipv4addrs", "ipv6addrs"} {
+		entries, _ := hr[field].([]any)
+		for _, e := range entries {
+			if entry, ok := e.(map[string]any); ok {
+				addresses = append(addresses, stringField(entry, strings.TrimSuffix(field, "s"), ""))
+			}
+		}
+	}
+	return addresses
+}
+
+func stringField(object map[string]any, field, fallback string) string {
+	if v, ok := object[field].(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func cmpOr(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
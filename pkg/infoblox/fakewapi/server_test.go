@@ -0,0 +1,167 @@
+package fakewapi_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox/fakewapi"
+)
+
+func newClient(t *testing.T, server *fakewapi.Server) infoblox.Client {
+	t.Helper()
+	c, err := infoblox.NewClient(infoblox.Config{
+		HostConfig: infoblox.HostConfig{
+			Host:     server.Host(),
+			Port:     server.Port(),
+			Version:  "2.12",
+			CustomCA: server.CACertificate(),
+		},
+		AuthConfig: infoblox.AuthConfig{Username: "admin", Password: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServerViewsAndNetworks(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("admin", "secret")
+	t.Cleanup(server.Close)
+	server.AddNetworkView("other")
+	server.AddNetwork("other", netip.MustParsePrefix("10.0.0.0/24"), nil)
+	c := newClient(t, server)
+
+	g.Expect(c.CheckNetworkViewExists("other")).To(BeTrue())
+	g.Expect(c.CheckNetworkViewExists("missing")).To(BeFalse())
+	g.Expect(c.CheckDNSViewExists(fakewapi.DefaultDNSView)).To(BeTrue())
+	g.Expect(c.CheckNetworkExists("other", netip.MustParsePrefix("10.0.0.0/24"))).To(BeTrue())
+	g.Expect(c.CheckNetworkExists(fakewapi.DefaultNetworkView, netip.MustParsePrefix("10.0.0.0/24"))).To(BeFalse())
+
+	info, err := c.GetGridInfo()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.Name).To(Equal(fakewapi.GridName))
+}
+
+func TestServerAllocatesAndReleasesAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("admin", "secret")
+	t.Cleanup(server.Close)
+	subnet := netip.MustParsePrefix("10.0.0.0/30")
+	server.AddNetwork(fakewapi.DefaultNetworkView, subnet, nil)
+	c := newClient(t, server)
+
+	first, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "first.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(first.Address).To(Equal(netip.MustParseAddr("10.0.0.1")))
+	g.Expect(server.HostRecordAddresses("first.example.com")).To(ConsistOf("10.0.0.1"))
+
+	again, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "first.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again.Address).To(Equal(first.Address))
+
+	second, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "second.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second.Address).To(Equal(netip.MustParseAddr("10.0.0.2")))
+
+	// 10.0.0.3 is the broadcast address of the subnet.
+	_, err = c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "third.example.com", "", klog.TODO())
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(c.ReleaseAddressByRef(first.Ref, first.Address, klog.TODO())).To(Succeed())
+	g.Expect(server.HostRecordAddresses("first.example.com")).To(BeEmpty())
+
+	third, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "third.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(third.Address).To(Equal(first.Address))
+
+	g.Expect(c.ReleaseAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "second.example.com", klog.TODO())).To(Succeed())
+	g.Expect(server.Objects("record:host")).To(HaveLen(1))
+}
+
+func TestServerAllocatesIPv6Addresses(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("", "")
+	t.Cleanup(server.Close)
+	subnet := netip.MustParsePrefix("fd00::/64")
+	server.AddNetwork(fakewapi.DefaultNetworkView, subnet, nil)
+	c := newClient(t, server)
+
+	allocation, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "host.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocation.Address).To(Equal(netip.MustParseAddr("fd00::1")))
+}
+
+func TestServerAllocatesNetworks(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("admin", "secret")
+	t.Cleanup(server.Close)
+	container := netip.MustParsePrefix("10.10.0.0/16")
+	server.AddNetworkContainer(fakewapi.DefaultNetworkView, container)
+	server.AddNetwork(fakewapi.DefaultNetworkView, netip.MustParsePrefix("10.10.0.0/24"), nil)
+	c := newClient(t, server)
+
+	network, err := c.GetOrAllocateNetwork(fakewapi.DefaultNetworkView, container, 24, "pool-a", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(network).To(Equal(netip.MustParsePrefix("10.10.1.0/24")))
+
+	again, err := c.GetOrAllocateNetwork(fakewapi.DefaultNetworkView, container, 24, "pool-a", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(network))
+
+	g.Expect(c.ReleaseNetwork(fakewapi.DefaultNetworkView, network, "pool-a", klog.TODO())).To(Succeed())
+	g.Expect(c.CheckNetworkExists(fakewapi.DefaultNetworkView, network)).To(BeFalse())
+}
+
+func TestServerRejectsInvalidCredentials(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("admin", "other")
+	t.Cleanup(server.Close)
+	c := newClient(t, server)
+
+	_, err := c.CheckNetworkViewExists(fakewapi.DefaultNetworkView)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestServerQuarantinesReleasedAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	server := fakewapi.NewServer("admin", "secret")
+	t.Cleanup(server.Close)
+	subnet := netip.MustParsePrefix("10.0.0.0/30")
+	server.AddNetwork(fakewapi.DefaultNetworkView, subnet, nil)
+	c := newClient(t, server)
+
+	first, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "first.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// An address can't be reserved while a host record still uses it.
+	until := time.Now().Add(time.Hour)
+	err = c.QuarantineAddress(fakewapi.DefaultNetworkView, first.Address, "first.example.com", "default/pool", until, klog.TODO())
+	g.Expect(err).To(MatchError(infoblox.ErrConflict))
+
+	g.Expect(c.ReleaseAddressByRef(first.Ref, first.Address, klog.TODO())).To(Succeed())
+	g.Expect(c.QuarantineAddress(fakewapi.DefaultNetworkView, first.Address, "first.example.com", "default/pool", until, klog.TODO())).To(Succeed())
+	g.Expect(c.QuarantineAddress(fakewapi.DefaultNetworkView, first.Address, "first.example.com", "default/pool", until, klog.TODO())).To(Succeed())
+	g.Expect(server.Objects("fixedaddress")).To(HaveLen(1))
+
+	// The quarantined address is not allocated again.
+	second, err := c.GetOrAllocateAddress(fakewapi.DefaultNetworkView, fakewapi.DefaultDNSView, subnet, "second.example.com", "", klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second.Address).NotTo(Equal(first.Address))
+
+	next, err := c.ReleaseQuarantinedAddresses(fakewapi.DefaultNetworkView, "default/pool", until.Add(time.Second), klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next.IsZero()).To(BeTrue())
+	g.Expect(server.Objects("fixedaddress")).To(BeEmpty())
+}
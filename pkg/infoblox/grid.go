@@ -0,0 +1,55 @@
+package infoblox
+
+import (
+	"time"
+
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+)
+
+// GridInfo contains information about the grid an instance is connected to.
+type GridInfo struct {
+	// Name of the grid.
+	Name string
+	// NIOSVersion is the version of NIOS running on the grid. It is empty if the user is not allowed to read the upgrade status.
+	NIOSVersion string
+	// WAPIVersion is the WAPI version used to talk to the grid.
+	WAPIVersion string
+	// Latency is the time it took to probe the grid.
+	Latency time.Duration
+	// Endpoint is the grid member, in the format host:port, that answered the probe.
+	Endpoint string
+}
+
+// GetGridInfo probes the WAPI by fetching the grid object and returns information about the grid.
+func (c *client) GetGridInfo() (GridInfo, error) {
+	start := time.Now()
+	var grids []struct {
+		Name string `json:"name"`
+	}
+	grid := ibclient.NewGrid(ibclient.Grid{})
+	grid.SetReturnFields([]string{"name"})
+	if err := c.connector.GetObject(grid, "", ibclient.NewQueryParams(false, nil), &grids); err != nil {
+		return GridInfo{}, classifyError(err)
+	}
+
+	info := GridInfo{
+		WAPIVersion: c.hc.Version,
+		Latency:     time.Since(start),
+		Endpoint:    c.requestor.activeEndpoint(),
+	}
+	if len(grids) > 0 {
+		info.Name = grids[0].Name
+	}
+
+	// The NIOS version is only informational, so errors are ignored, e.g. if the user lacks the permission to read it.
+	var statuses []struct {
+		CurrentVersion string `json:"current_version"`
+	}
+	upgradeStatus := ibclient.NewUpgradeStatus(ibclient.UpgradeStatus{})
+	upgradeStatus.SetReturnFields([]string{"current_version"})
+	if err := c.connector.GetObject(upgradeStatus, "", ibclient.NewQueryParams(false, map[string]string{"type": "GRID"}), &statuses); err == nil && len(statuses) > 0 {
+		info.NIOSVersion = statuses[0].CurrentVersion
+	}
+
+	return info, nil
+}
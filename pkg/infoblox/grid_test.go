@@ -0,0 +1,26 @@
+package infoblox
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClientGetGridInfo(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	c, err := NewClient(testConfig(t, server))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	info, err := c.GetGridInfo()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.Name).To(Equal("Infoblox"))
+	g.Expect(info.NIOSVersion).To(Equal("9.0.3-50212-ee11d5834df9"))
+	g.Expect(info.WAPIVersion).To(Equal("2.12"))
+	g.Expect(info.Latency).To(BeNumerically(">", 0))
+
+	server.Close()
+	_, err = c.GetGridInfo()
+	g.Expect(err).To(HaveOccurred(), "should fail if the grid is unreachable")
+}
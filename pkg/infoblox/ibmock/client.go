@@ -12,6 +12,7 @@ package ibmock
 import (
 	netip "net/netip"
 	reflect "reflect"
+	time "time"
 
 	logr "github.com/go-logr/logr"
 	infoblox "github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox"
@@ -87,6 +88,36 @@ func (mr *MockClientMockRecorder) CheckNetworkViewExists(view any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckNetworkViewExists", reflect.TypeOf((*MockClient)(nil).CheckNetworkViewExists), view)
 }
 
+// GetAddressByRef mocks base method.
+func (m *MockClient) GetAddressByRef(ref string, address netip.Addr) (infoblox.Allocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddressByRef", ref, address)
+	ret0, _ := ret[0].(infoblox.Allocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddressByRef indicates an expected call of GetAddressByRef.
+func (mr *MockClientMockRecorder) GetAddressByRef(ref, address any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressByRef", reflect.TypeOf((*MockClient)(nil).GetAddressByRef), ref, address)
+}
+
+// GetGridInfo mocks base method.
+func (m *MockClient) GetGridInfo() (infoblox.GridInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGridInfo")
+	ret0, _ := ret[0].(infoblox.GridInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGridInfo indicates an expected call of GetGridInfo.
+func (mr *MockClientMockRecorder) GetGridInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGridInfo", reflect.TypeOf((*MockClient)(nil).GetGridInfo))
+}
+
 // GetHostConfig mocks base method.
 func (m *MockClient) GetHostConfig() *infoblox.HostConfig {
 	m.ctrl.T.Helper()
@@ -102,10 +133,10 @@ func (mr *MockClientMockRecorder) GetHostConfig() *gomock.Call {
 }
 
 // GetOrAllocateAddress mocks base method.
-func (m *MockClient) GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, zone string, logger logr.Logger) (netip.Addr, error) {
+func (m *MockClient) GetOrAllocateAddress(networkView, dnsView string, subnet netip.Prefix, hostname, zone string, logger logr.Logger) (infoblox.Allocation, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOrAllocateAddress", networkView, dnsView, subnet, hostname, zone, logger)
-	ret0, _ := ret[0].(netip.Addr)
+	ret0, _ := ret[0].(infoblox.Allocation)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -116,6 +147,50 @@ func (mr *MockClientMockRecorder) GetOrAllocateAddress(networkView, dnsView, sub
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrAllocateAddress", reflect.TypeOf((*MockClient)(nil).GetOrAllocateAddress), networkView, dnsView, subnet, hostname, zone, logger)
 }
 
+// GetOrAllocateNetwork mocks base method.
+func (m *MockClient) GetOrAllocateNetwork(view string, container netip.Prefix, prefixLength int, owner string, logger logr.Logger) (netip.Prefix, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrAllocateNetwork", view, container, prefixLength, owner, logger)
+	ret0, _ := ret[0].(netip.Prefix)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrAllocateNetwork indicates an expected call of GetOrAllocateNetwork.
+func (mr *MockClientMockRecorder) GetOrAllocateNetwork(view, container, prefixLength, owner, logger any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrAllocateNetwork", reflect.TypeOf((*MockClient)(nil).GetOrAllocateNetwork), view, container, prefixLength, owner, logger)
+}
+
+// GetSchema mocks base method.
+func (m *MockClient) GetSchema(version infoblox.WAPIVersion) (infoblox.Schema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchema", version)
+	ret0, _ := ret[0].(infoblox.Schema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchema indicates an expected call of GetSchema.
+func (mr *MockClientMockRecorder) GetSchema(version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchema", reflect.TypeOf((*MockClient)(nil).GetSchema), version)
+}
+
+// QuarantineAddress mocks base method.
+func (m *MockClient) QuarantineAddress(networkView string, address netip.Addr, name, owner string, until time.Time, logger logr.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuarantineAddress", networkView, address, name, owner, until, logger)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QuarantineAddress indicates an expected call of QuarantineAddress.
+func (mr *MockClientMockRecorder) QuarantineAddress(networkView, address, name, owner, until, logger any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuarantineAddress", reflect.TypeOf((*MockClient)(nil).QuarantineAddress), networkView, address, name, owner, until, logger)
+}
+
 // ReleaseAddress mocks base method.
 func (m *MockClient) ReleaseAddress(networkView, dnsView string, subnet netip.Prefix, hostname string, logger logr.Logger) error {
 	m.ctrl.T.Helper()
@@ -129,3 +204,46 @@ func (mr *MockClientMockRecorder) ReleaseAddress(networkView, dnsView, subnet, h
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseAddress", reflect.TypeOf((*MockClient)(nil).ReleaseAddress), networkView, dnsView, subnet, hostname, logger)
 }
+
+// ReleaseAddressByRef mocks base method.
+func (m *MockClient) ReleaseAddressByRef(ref string, address netip.Addr, logger logr.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseAddressByRef", ref, address, logger)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseAddressByRef indicates an expected call of ReleaseAddressByRef.
+func (mr *MockClientMockRecorder) ReleaseAddressByRef(ref, address, logger any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseAddressByRef", reflect.TypeOf((*MockClient)(nil).ReleaseAddressByRef), ref, address, logger)
+}
+
+// ReleaseNetwork mocks base method.
+func (m *MockClient) ReleaseNetwork(view string, subnet netip.Prefix, owner string, logger logr.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseNetwork", view, subnet, owner, logger)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseNetwork indicates an expected call of ReleaseNetwork.
+func (mr *MockClientMockRecorder) ReleaseNetwork(view, subnet, owner, logger any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseNetwork", reflect.TypeOf((*MockClient)(nil).ReleaseNetwork), view, subnet, owner, logger)
+}
+
+// ReleaseQuarantinedAddresses mocks base method.
+func (m *MockClient) ReleaseQuarantinedAddresses(networkView, owner string, now time.Time, logger logr.Logger) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseQuarantinedAddresses", networkView, owner, now, logger)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseQuarantinedAddresses indicates an expected call of ReleaseQuarantinedAddresses.
+func (mr *MockClientMockRecorder) ReleaseQuarantinedAddresses(networkView, owner, now, logger any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseQuarantinedAddresses", reflect.TypeOf((*MockClient)(nil).ReleaseQuarantinedAddresses), networkView, owner, now, logger)
+}
@@ -0,0 +1,115 @@
+package infoblox
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/go-logr/logr"
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+)
+
+// OwnerExtensibleAttribute is the name of the extensible attribute used to mark networks created by the provider.
+// The attribute needs to be defined as a string attribute in Infoblox.
+const OwnerExtensibleAttribute = "CAPI IPAM Owner"
+
+// getOwnedNetwork returns the network matching the given search fields that is owned by owner, or nil if there is none.
+func (c *client) getOwnedNetwork(view string, isIPv6 bool, owner string, searchFields map[string]string) (*ibclient.Network, error) {
+	params := map[string]string{
+		"network_view":                 view,
+		"*" + OwnerExtensibleAttribute: owner,
+	}
+	for k, v := range searchFields {
+		params[k] = v
+	}
+
+	var networks []ibclient.Network
+	err := c.connector.GetObject(ibclient.NewNetwork(view, "", isIPv6, "", nil), "", ibclient.NewQueryParams(false, params), &networks)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, classifyError(err)
+	}
+	switch len(networks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &networks[0], nil
+	default:
+		return nil, fmt.Errorf("multiple networks owned by %q found in network view %q", owner, view)
+	}
+}
+
+// GetOrAllocateNetwork returns the network owned by owner in the given network container.
+//
+// If no such network exists, the next available network with the given prefix length is created in the container.
+func (c *client) GetOrAllocateNetwork(view string, container netip.Prefix, prefixLength int, owner string, logger logr.Logger) (netip.Prefix, error) {
+	if err := c.requireFeature(FeatureExtensibleAttributes); err != nil {
+		return netip.Prefix{}, err
+	}
+	if container.Addr().Is6() {
+		if err := c.requireFeature(FeatureIPv6NetworkContainers); err != nil {
+			return netip.Prefix{}, err
+		}
+	}
+
+	network, err := c.getOwnedNetwork(view, container.Addr().Is6(), owner, map[string]string{"network_container": container.String()})
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to get Infoblox network: %w", err)
+	}
+
+	if network == nil {
+		logger.Info("Allocating Infoblox network", "networkContainer", container, "prefixLength", prefixLength)
+		prefixLen := uint(prefixLength) //nolint:gosec // prefix length is validated to be 1-128
+		network, err = c.objMgr.AllocateNetwork(view, container.String(), container.Addr().Is6(), prefixLen, "", ibclient.EA{
+			OwnerExtensibleAttribute: owner,
+		})
+		change := Change{Operation: ChangeCreate, ObjectType: networkObjectType(container.Addr().Is6()), Name: container.String()}
+		if c.dryRun {
+			// The synthetic reference doesn't contain the CIDR of the network, so it can't be parsed.
+			c.recordChange(change, nil)
+			return netip.Prefix{}, fmt.Errorf("%w: would allocate the next available /%d network in network container %s", ErrDryRun, prefixLength, container)
+		}
+		if err == nil {
+			change.Name, change.Ref = network.Cidr, network.Ref
+		}
+		c.recordChange(change, err)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("failed to allocate Infoblox network: %w", classifyError(err))
+		}
+	}
+
+	subnet, err := netip.ParsePrefix(network.Cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to parse network returned by Infoblox: %w", err)
+	}
+	return subnet, nil
+}
+
+// ReleaseNetwork deletes the given network. Networks that are not owned by owner are left untouched.
+func (c *client) ReleaseNetwork(view string, subnet netip.Prefix, owner string, logger logr.Logger) error {
+	network, err := c.getOwnedNetwork(view, subnet.Addr().Is6(), owner, map[string]string{"network": subnet.String()})
+	if err != nil {
+		return fmt.Errorf("failed to get Infoblox network: %w", err)
+	}
+	if network == nil {
+		// The network is either gone or not ours, so we don't need to do anything.
+		return nil
+	}
+
+	logger.Info("Deleting Infoblox network", "subnet", subnet)
+	_, err = c.connector.DeleteObject(network.Ref)
+	c.recordChange(Change{Operation: ChangeDelete, ObjectType: networkObjectType(subnet.Addr().Is6()), Name: subnet.String(), Ref: network.Ref}, err)
+	if err != nil {
+		return fmt.Errorf("failed to delete Infoblox network: %w", classifyError(err))
+	}
+	return nil
+}
+
+// networkObjectType returns the WAPI object type of IPv4 or IPv6 networks.
+func networkObjectType(isIPv6 bool) string {
+	if isIPv6 {
+		return "ipv6network"
+	}
+	return "network"
+}
@@ -0,0 +1,121 @@
+package infoblox
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/go-logr/logr"
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+	"k8s.io/utils/ptr"
+)
+
+// QuarantineExtensibleAttribute is the name of the extensible attribute holding the time, in RFC 3339 format,
+// until which a released address is reserved. The attribute needs to be defined as a string attribute in Infoblox.
+const QuarantineExtensibleAttribute = "CAPI IPAM Quarantine Until"
+
+// reservationMAC is the MAC address of fixed addresses that are reservations.
+const reservationMAC = "00:00:00:00:00:00"
+
+// getQuarantinedAddresses returns the reservations owned by owner that match the given search fields.
+func (c *client) getQuarantinedAddresses(networkView, owner string, searchFields map[string]string) ([]ibclient.FixedAddress, error) {
+	params := map[string]string{
+		"network_view":                 networkView,
+		"*" + OwnerExtensibleAttribute: owner,
+	}
+	for k, v := range searchFields {
+		params[k] = v
+	}
+
+	var reservations []ibclient.FixedAddress
+	err := c.connector.GetObject(ibclient.NewEmptyFixedAddress(false), "", ibclient.NewQueryParams(false, params), &reservations)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, classifyError(err)
+	}
+	return reservations, nil
+}
+
+// QuarantineAddress reserves a released address until the given time, so it isn't allocated again immediately.
+// Only IPv4 addresses can be reserved. An existing reservation of the address owned by owner is kept.
+func (c *client) QuarantineAddress(networkView string, address netip.Addr, name, owner string, until time.Time, logger logr.Logger) error {
+	if err := c.requireFeature(FeatureExtensibleAttributes); err != nil {
+		return err
+	}
+	if !address.Is4() {
+		return errors.New("only IPv4 addresses can be quarantined")
+	}
+
+	existing, err := c.getQuarantinedAddresses(networkView, owner, map[string]string{"ipv4addr": address.String()})
+	if err != nil {
+		return fmt.Errorf("failed to get Infoblox reservation: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	reservation := ibclient.NewEmptyFixedAddress(false)
+	reservation.NetviewName = networkView
+	reservation.IPv4Address = address.String()
+	reservation.Mac = ptr.To(reservationMAC)
+	reservation.MatchClient = ptr.To("MAC_ADDRESS")
+	reservation.Name = ptr.To(name)
+	reservation.Comment = "Quarantined after release"
+	reservation.Ea = ibclient.EA{
+		OwnerExtensibleAttribute:      owner,
+		QuarantineExtensibleAttribute: until.UTC().Format(time.RFC3339),
+	}
+
+	logger.Info("Creating Infoblox reservation", "address", address, "until", until)
+	ref, err := c.connector.CreateObject(reservation)
+	c.recordChange(Change{Operation: ChangeCreate, ObjectType: reservation.ObjectType(), Name: name, Ref: ref, Addresses: []string{address.String()}}, err)
+	if err != nil {
+		return fmt.Errorf("failed to create Infoblox reservation: %w", classifyError(err))
+	}
+	return nil
+}
+
+// ReleaseQuarantinedAddresses deletes the reservations owned by owner whose quarantine ended at or before now.
+// All reservations owned by owner are deleted if now is zero. The end of the earliest remaining quarantine is returned,
+// or the zero time if no reservations remain.
+func (c *client) ReleaseQuarantinedAddresses(networkView, owner string, now time.Time, logger logr.Logger) (time.Time, error) {
+	if err := c.requireFeature(FeatureExtensibleAttributes); err != nil {
+		return time.Time{}, err
+	}
+
+	reservations, err := c.getQuarantinedAddresses(networkView, owner, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get Infoblox reservations: %w", err)
+	}
+
+	var next time.Time
+	var errs []error
+	for _, reservation := range reservations {
+		value, ok := reservation.Ea[QuarantineExtensibleAttribute].(string)
+		if !ok {
+			// Not a reservation made by the provider.
+			continue
+		}
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			logger.Error(err, "failed to parse end of quarantine, releasing reservation", "address", reservation.IPv4Address)
+		}
+		if err == nil && !now.IsZero() && until.After(now) {
+			if next.IsZero() || until.Before(next) {
+				next = until
+			}
+			continue
+		}
+
+		logger.Info("Deleting Infoblox reservation", "address", reservation.IPv4Address)
+		_, err = c.connector.DeleteObject(reservation.Ref)
+		c.recordChange(Change{Operation: ChangeDelete, ObjectType: "fixedaddress", Name: ptr.Deref(reservation.Name, ""), Ref: reservation.Ref, Addresses: []string{reservation.IPv4Address}}, err)
+		if err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete Infoblox reservation of %s: %w", reservation.IPv4Address, classifyError(err)))
+		}
+	}
+	return next, errors.Join(errs...)
+}
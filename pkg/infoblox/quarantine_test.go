@@ -0,0 +1,91 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+)
+
+func TestQuarantineAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	const owner = "default/pool"
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var created []map[string]any
+	var deleted []string
+	reservations := []string{
+		`{"_ref":"fixedaddress/expired:10.0.0.5/default","ipv4addr":"10.0.0.5","name":"expired","extattrs":{"CAPI IPAM Owner":{"value":"default/pool"},"CAPI IPAM Quarantine Until":{"value":"2024-05-01T11:00:00Z"}}}`,
+		`{"_ref":"fixedaddress/pending:10.0.0.6/default","ipv4addr":"10.0.0.6","name":"pending","extattrs":{"CAPI IPAM Owner":{"value":"default/pool"},"CAPI IPAM Quarantine Until":{"value":"2024-05-01T12:30:00Z"}}}`,
+		`{"_ref":"fixedaddress/other:10.0.0.7/default","ipv4addr":"10.0.0.7","name":"other","extattrs":{"CAPI IPAM Owner":{"value":"default/pool"}}}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wapi/v2.12/fixedaddress", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("*CAPI IPAM Owner") != owner {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			if r.URL.Query().Has("ipv4addr") {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[` + strings.Join(reservations, ",") + `]`))
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			var object map[string]any
+			_ = json.Unmarshal(body, &object)
+			created = append(created, object)
+			_, _ = w.Write([]byte(`"fixedaddress/new:10.0.0.2/default"`))
+		}
+	})
+	mux.HandleFunc("/wapi/v2.12/fixedaddress/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		ref := strings.TrimPrefix(r.URL.Path, "/wapi/v2.12/")
+		deleted = append(deleted, ref)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`"` + ref + `"`))
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := NewClient(testConfig(t, server))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.QuarantineAddress("default", netip.MustParseAddr("10.0.0.2"), "host.example.com", owner, now.Add(time.Hour), klog.TODO())).To(Succeed())
+	g.Expect(created).To(HaveLen(1))
+	g.Expect(created[0]).To(SatisfyAll(
+		HaveKeyWithValue("ipv4addr", "10.0.0.2"),
+		HaveKeyWithValue("mac", reservationMAC),
+		HaveKeyWithValue("name", "host.example.com"),
+		HaveKeyWithValue("extattrs", HaveKeyWithValue(QuarantineExtensibleAttribute, HaveKeyWithValue("value", "2024-05-01T13:00:00Z"))),
+	))
+
+	g.Expect(c.QuarantineAddress("default", netip.MustParseAddr("fd00::2"), "host.example.com", owner, now.Add(time.Hour), klog.TODO())).NotTo(Succeed())
+
+	next, err := c.ReleaseQuarantinedAddresses("default", owner, now, klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(Equal(now.Add(30 * time.Minute)))
+	g.Expect(deleted).To(ConsistOf("fixedaddress/expired:10.0.0.5/default"))
+
+	deleted = nil
+	next, err = c.ReleaseQuarantinedAddresses("default", owner, time.Time{}, klog.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(BeZero())
+	g.Expect(deleted).To(ConsistOf("fixedaddress/expired:10.0.0.5/default", "fixedaddress/pending:10.0.0.6/default"))
+}
@@ -0,0 +1,121 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+)
+
+const (
+	// FeatureIPv6 indicates that addresses can be allocated from IPv6 networks.
+	FeatureIPv6 = "IPv6"
+	// FeatureIPv6NetworkContainers indicates that IPv6 networks can be allocated from network containers.
+	FeatureIPv6NetworkContainers = "IPv6NetworkContainers"
+	// FeatureExtensibleAttributes indicates that objects can be tagged with extensible attributes, which is required to track ownership of networks.
+	FeatureExtensibleAttributes = "ExtensibleAttributes"
+)
+
+// featureObjects maps features to the WAPI object they depend on.
+var featureObjects = map[string]string{
+	FeatureIPv6:                  "ipv6network",
+	FeatureIPv6NetworkContainers: "ipv6networkcontainer",
+	FeatureExtensibleAttributes:  "extensibleattributedef",
+}
+
+// Schema describes the capabilities of a WAPI.
+type Schema struct {
+	// SupportedVersions are the WAPI versions supported by the grid, sorted from oldest to newest.
+	SupportedVersions []WAPIVersion
+	// SupportedObjects are the WAPI objects available in the requested version.
+	SupportedObjects []string
+}
+
+// schemaResponse is the response of a WAPI schema request.
+type schemaResponse struct {
+	SupportedVersions []string `json:"supported_versions"`
+	SupportedObjects  []string `json:"supported_objects"`
+}
+
+// GetSchema queries the WAPI schema using the given WAPI version, independent of the version configured for the client.
+// The supported versions are the same for all versions, while the supported objects depend on the requested version.
+func (c *client) GetSchema(version WAPIVersion) (Schema, error) {
+	host, port := endpoint(c.hc)
+	u := url.URL{
+		Scheme:   "https",
+		Host:     host + ":" + port,
+		Path:     path.Join("/", c.hc.PathPrefix, "wapi", "v"+version.String()) + "/",
+		RawQuery: "_schema",
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return Schema{}, err
+	}
+	if c.auth.Source == nil && c.auth.Username != "" {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+
+	body, err := c.requestor.SendRequest(req)
+	if err != nil {
+		return Schema{}, fmt.Errorf("failed to query WAPI schema: %w", classifyError(err))
+	}
+	response := schemaResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Schema{}, fmt.Errorf("failed to parse WAPI schema: %w", err)
+	}
+
+	schema := Schema{SupportedObjects: response.SupportedObjects}
+	for _, v := range response.SupportedVersions {
+		version, err := ParseWAPIVersion(v)
+		if err != nil {
+			// versions that can't be parsed can't be configured either
+			continue
+		}
+		schema.SupportedVersions = append(schema.SupportedVersions, version)
+	}
+	slices.SortFunc(schema.SupportedVersions, WAPIVersion.Compare)
+	return schema, nil
+}
+
+// NegotiateVersion returns the WAPI version to use. If a version is requested, it must be supported by the grid.
+// Otherwise the newest supported version is selected.
+func (s Schema) NegotiateVersion(requested string) (WAPIVersion, error) {
+	if requested != "" {
+		version, err := ParseWAPIVersion(requested)
+		if err != nil {
+			return WAPIVersion{}, err
+		}
+		if !slices.Contains(s.SupportedVersions, version) {
+			return WAPIVersion{}, fmt.Errorf("WAPI version %s is not supported by the grid, supported versions are %v", version, s.SupportedVersions)
+		}
+		return version, nil
+	}
+
+	if len(s.SupportedVersions) == 0 || s.SupportedVersions[len(s.SupportedVersions)-1].Compare(MinimumWAPIVersion) < 0 {
+		return WAPIVersion{}, fmt.Errorf("the grid does not support WAPI version %s or newer, supported versions are %v", MinimumWAPIVersion, s.SupportedVersions)
+	}
+	return s.SupportedVersions[len(s.SupportedVersions)-1], nil
+}
+
+// Features returns the features supported by the schema, sorted by name.
+func (s Schema) Features() []string {
+	features := []string{}
+	for feature, object := range featureObjects {
+		if slices.Contains(s.SupportedObjects, object) {
+			features = append(features, feature)
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+// requireFeature returns an error if the grid is known not to support a feature.
+// All features are assumed to be supported if the features of the grid are unknown.
+func (c *client) requireFeature(feature string) error {
+	if c.hc.Features == nil || slices.Contains(c.hc.Features, feature) {
+		return nil
+	}
+	return fmt.Errorf("the grid does not support %s with WAPI version %s", feature, c.hc.Version)
+}
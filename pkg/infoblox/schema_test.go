@@ -0,0 +1,53 @@
+package infoblox
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+func TestClientGetSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "/infoblox")
+	config := testConfig(t, server)
+	config.PathPrefix = "/infoblox"
+	config.Version = "1.0"
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	schema, err := c.GetSchema(MinimumWAPIVersion)
+	g.Expect(err).NotTo(HaveOccurred(), "should not depend on the configured version")
+	g.Expect(schema.SupportedVersions).To(Equal([]WAPIVersion{{1, 0, 0}, {2, 5, 0}, {2, 12, 0}, {2, 12, 3}}))
+	g.Expect(schema.Features()).To(Equal([]string{FeatureIPv6}))
+
+	schema, err = c.GetSchema(WAPIVersion{Major: 2, Minor: 12})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(schema.Features()).To(Equal([]string{FeatureExtensibleAttributes, FeatureIPv6}))
+}
+
+func TestSchemaNegotiateVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	schema := Schema{SupportedVersions: []WAPIVersion{{1, 0, 0}, {2, 5, 0}, {2, 12, 0}, {2, 12, 3}}}
+	g.Expect(schema.NegotiateVersion("")).To(Equal(WAPIVersion{2, 12, 3}), "should select the newest version")
+	g.Expect(schema.NegotiateVersion("2.12")).To(Equal(WAPIVersion{2, 12, 0}))
+	_, err := schema.NegotiateVersion("2.13")
+	g.Expect(err).To(MatchError(ContainSubstring("WAPI version 2.13 is not supported by the grid")))
+
+	schema = Schema{SupportedVersions: []WAPIVersion{{1, 0, 0}, {2, 3, 0}}}
+	_, err = schema.NegotiateVersion("")
+	g.Expect(err).To(MatchError(ContainSubstring("does not support WAPI version 2.5 or newer")))
+}
+
+func TestClientRequiresFeatures(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &client{hc: HostConfig{Version: "2.12", Features: []string{FeatureExtensibleAttributes}}}
+	_, err := c.GetOrAllocateAddress("default", "", netip.MustParsePrefix("2001:db8::/64"), "host", "", logr.Discard())
+	g.Expect(err).To(MatchError("the grid does not support IPv6 with WAPI version 2.12"))
+	_, err = c.GetOrAllocateNetwork("default", netip.MustParsePrefix("2001:db8::/48"), 64, "owner", logr.Discard())
+	g.Expect(err).To(MatchError("the grid does not support IPv6NetworkContainers with WAPI version 2.12"))
+}
@@ -0,0 +1,382 @@
+package infoblox
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ibclient "github.com/infobloxopen/infoblox-go-client/v2"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultPort         = "443"
+	maxIdleConnsPerHost = 5
+	dialTimeout         = 10 * time.Second
+)
+
+const (
+	// DefaultRequestTimeout is the timeout of a single request if none is configured.
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// DefaultRetryConfig is the retry configuration used by the provider if none is configured.
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries:     3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// endpoint returns the host and port to connect to.
+// For backwards compatibility, the port can also be part of the host if no port is configured.
+func endpoint(hc HostConfig) (string, string) {
+	host, port := hc.Host, hc.Port
+	if port == "" {
+		port = defaultPort
+		if h, p, err := net.SplitHostPort(hc.Host); err == nil {
+			host, port = h, p
+		}
+	}
+	// ibclient joins host and port with a colon, so IPv6 addresses need to be enclosed in brackets.
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		host = "[" + host + "]"
+	}
+	return host, port
+}
+
+// requestBuilder builds WAPI requests and prepends the configured path prefix to the WAPI path.
+type requestBuilder struct {
+	ibclient.WapiRequestBuilder
+	pathPrefix string
+}
+
+var _ ibclient.HttpRequestBuilder = &requestBuilder{}
+
+// BuildRequest builds a WAPI request.
+func (b *requestBuilder) BuildRequest(t ibclient.RequestType, obj ibclient.IBObject, ref string, queryParams *ibclient.QueryParams) (*http.Request, error) {
+	req, err := b.WapiRequestBuilder.BuildRequest(t, obj, ref, queryParams)
+	if err != nil || b.pathPrefix == "" {
+		return req, err
+	}
+	req.URL.Path = path.Join("/", b.pathPrefix, req.URL.Path)
+	req.URL.RawPath = ""
+	return req, nil
+}
+
+// requestor sends WAPI requests using a preconfigured HTTP client.
+// If several endpoints are configured, requests are sent to the active one and fail over to the others if it is unavailable.
+type requestor struct {
+	client      *http.Client
+	credentials CredentialSource
+	limiter     *rate.Limiter
+	retry       RetryConfig
+	endpoints   []string
+	active      atomic.Int32
+}
+
+// newRequestor creates a requestor for the endpoints of a host config, starting with the preferred endpoint if it is one of them.
+func newRequestor(client *http.Client, hc HostConfig, credentials CredentialSource) *requestor {
+	r := &requestor{client: client, credentials: credentials, limiter: hc.RateLimiter, retry: hc.Retry}
+	host, port := endpoint(hc)
+	r.endpoints = append(r.endpoints, host+":"+port)
+	for _, e := range hc.FailoverEndpoints {
+		host, port := endpoint(HostConfig{Host: e.Host, Port: cmp.Or(e.Port, hc.Port)})
+		r.endpoints = append(r.endpoints, host+":"+port)
+	}
+	if i := slices.Index(r.endpoints, hc.PreferredEndpoint); i > 0 {
+		r.active.Store(int32(i)) //nolint:gosec // the number of endpoints is small
+	}
+	return r
+}
+
+// activeEndpoint returns the endpoint requests are currently sent to.
+func (r *requestor) activeEndpoint() string {
+	return r.endpoints[r.active.Load()]
+}
+
+// do sends a request to the active endpoint. If the endpoint is unavailable, the request is sent to the next one,
+// which becomes the active endpoint if it answers. See canFailOver and canFailOverResponse for requests that modify objects.
+func (r *requestor) do(req *http.Request) (*http.Response, error) {
+	active := int(r.active.Load())
+	var errs []error
+	for i := range r.endpoints {
+		idx := (active + i) % len(r.endpoints)
+		attempt := req.Clone(req.Context())
+		attempt.URL.Host = r.endpoints[idx]
+		attempt.Host = ""
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		resp, err := r.client.Do(attempt)
+		if err == nil {
+			if !isUnavailable(resp.StatusCode) {
+				r.active.Store(int32(idx)) //nolint:gosec // the number of endpoints is small
+				return resp, nil
+			}
+			// The response of the last endpoint is returned even if it is unavailable, so its error can be reported.
+			if i == len(r.endpoints)-1 || !canFailOverResponse(attempt, resp.StatusCode) {
+				return resp, nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", r.endpoints[idx], resp.Status))
+			resp.Body.Close()
+			continue
+		}
+		errs = append(errs, err)
+		if !canFailOver(attempt, err) {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// doWithRetry sends a request, waiting for the rate limiter before every attempt.
+// Throttled requests and requests that failed with a server error are retried with exponential backoff.
+// Without an initial backoff, the one of DefaultRetryConfig is used, so retries never hit an overloaded grid back to back.
+func (r *requestor) doWithRetry(req *http.Request) (*http.Response, error) {
+	backoff := cmp.Or(r.retry.InitialBackoff, DefaultRetryConfig.InitialBackoff)
+	for attempt := 0; ; attempt++ {
+		if r.limiter != nil {
+			if err := r.limiter.Wait(req.Context()); err != nil {
+				return nil, fmt.Errorf("rate limit: %w", err)
+			}
+		}
+
+		resp, err := r.do(req)
+		last := attempt >= r.retry.MaxRetries
+		switch {
+		case err != nil:
+			if last || !canFailOver(req, err) {
+				return nil, err
+			}
+		case !shouldRetry(req, resp.StatusCode) || last:
+			return resp, nil
+		default:
+			if d := retryAfter(resp); d > backoff {
+				backoff = d
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if r.retry.MaxBackoff > 0 && backoff > r.retry.MaxBackoff {
+			backoff = r.retry.MaxBackoff
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// shouldRetry returns whether a request should be retried after receiving a response with the given status code.
+// Throttled and unavailable requests were not processed, so they are always retried.
+// Other server errors are only retried for requests that don't modify objects, since they might have been applied.
+func shouldRetry(req *http.Request, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError && req.Method == http.MethodGet
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a response, or zero if there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isUnavailable returns whether a status code indicates that a grid member can't process requests, e.g. because it is in maintenance.
+func isUnavailable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// canFailOver returns whether a request that failed with an error can be sent to another endpoint.
+// Requests that modify objects are only retried if the connection could not be established, so they are not applied twice.
+func canFailOver(req *http.Request, err error) bool {
+	if req.Method == http.MethodGet {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// canFailOverResponse returns whether a request that received a response with an unavailable status code can be sent to another endpoint.
+// Requests that modify objects only fail over if the grid member itself was unavailable, since a proxy answering
+// with a bad gateway or gateway timeout might have forwarded the request already.
+func canFailOverResponse(req *http.Request, statusCode int) bool {
+	return req.Method == http.MethodGet || statusCode == http.StatusServiceUnavailable
+}
+
+var _ ibclient.HttpRequestor = &requestor{}
+
+// Init is a no-op, since the HTTP client is configured when creating the requestor.
+func (r *requestor) Init(ibclient.AuthConfig, ibclient.TransportConfig) {}
+
+// SendRequest sends a request and returns the response body. Unsuccessful responses are returned as an *Error.
+func (r *requestor) SendRequest(req *http.Request) ([]byte, error) {
+	if r.credentials != nil {
+		creds, err := r.credentials.Credentials()
+		if err != nil {
+			return nil, &Error{Kind: ErrAuthFailed, err: fmt.Errorf("failed to get credentials: %w", err)}
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := r.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if refresher, ok := r.credentials.(credentialRefresher); ok && resp.StatusCode == http.StatusUnauthorized {
+		refresher.refresh()
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusOK || (resp.StatusCode == http.StatusCreated && req.Method == http.MethodPost) {
+		return content, nil
+	}
+
+	return nil, newResponseError(resp.StatusCode, content)
+}
+
+// newHTTPClient creates the HTTP client used to talk to the WAPI, configured for TLS, client certificates and proxies.
+func newHTTPClient(config Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.DisableTLSVerification, //nolint:gosec // explicitly requested by the user
+		Renegotiation:      tls.RenegotiateOnceAsClient,
+	}
+
+	if !config.DisableTLSVerification {
+		pool, err := customCAPool(config.HostConfig)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case config.Source != nil:
+		tlsConfig.GetClientCertificate = clientCertificateFunc(config.Source)
+	case len(config.ClientCert) > 0 && len(config.ClientKey) > 0:
+		cert, err := tls.X509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy, err := proxyFunc(config.HostConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Jar:     jar,
+		Timeout: cmp.Or(config.RequestTimeout, DefaultRequestTimeout),
+		Transport: &http.Transport{
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			Proxy:               proxy,
+			// Unreachable endpoints need to be detected quickly to fail over to the next one.
+			DialContext: (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		},
+	}, nil
+}
+
+// clientCertificateFunc returns a function that loads the client certificate from a credential source for every TLS handshake.
+// No certificate is sent if the source provides a username and password instead.
+func clientCertificateFunc(source CredentialSource) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		creds, err := source.Credentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials: %w", err)
+		}
+		if len(creds.ClientCert) == 0 || len(creds.ClientKey) == 0 {
+			return &tls.Certificate{}, nil
+		}
+		cert, err := tls.X509KeyPair(creds.ClientCert, creds.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+}
+
+// customCAPool returns the pool of custom certificate authorities, or nil if the system pool should be used.
+// CustomCA takes precedence over CustomCAPath.
+func customCAPool(hc HostConfig) (*x509.CertPool, error) {
+	caPEM := hc.CustomCA
+	if len(caPEM) == 0 {
+		if hc.CustomCAPath == "" {
+			return nil, nil
+		}
+		var err error
+		caPEM, err = os.ReadFile(hc.CustomCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom CA file: %w", err)
+		}
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("custom CA does not contain any PEM encoded certificates")
+	}
+	return pool, nil
+}
+
+// proxyFunc returns the proxy function for the transport. If no proxy is configured, the proxy environment variables are used.
+func proxyFunc(hc HostConfig) (func(*http.Request) (*url.URL, error), error) {
+	if hc.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(hc.ProxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", hc.ProxyURL)
+	}
+
+	fn := (&httpproxy.Config{
+		HTTPProxy:  hc.ProxyURL,
+		HTTPSProxy: hc.ProxyURL,
+		NoProxy:    strings.Join(hc.NoProxy, ","),
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}, nil
+}
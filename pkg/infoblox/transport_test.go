@@ -0,0 +1,388 @@
+package infoblox
+
+import (
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+)
+
+// newWAPIStandIn starts a local HTTPS server that answers schema, network view, grid and upgrade status requests on the given path prefix.
+func newWAPIStandIn(t *testing.T, pathPrefix string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathPrefix+"/wapi/v2.12/networkview", func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("name") != "default" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"_ref":"networkview/ZG5zLm5ldHdvcmtfdmlldyQw:default/true","name":"default"}]`))
+	})
+	mux.HandleFunc(pathPrefix+"/wapi/v2.12/grid", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"_ref":"grid/b25lLmNsdXN0ZXIkMA:Infoblox","name":"Infoblox"}]`))
+	})
+	mux.HandleFunc(pathPrefix+"/wapi/v2.12/upgradestatus", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("type") != "GRID" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"_ref":"upgradestatus/Li51cGdyYWRlc3RhdHVzJGdyaWQ:Infoblox","current_version":"9.0.3-50212-ee11d5834df9"}]`))
+	})
+	for version, objects := range map[string]string{
+		"2.5":  `["grid","ipv6network","networkview"]`,
+		"2.12": `["extensibleattributedef","grid","ipv6network","networkview"]`,
+	} {
+		mux.HandleFunc(pathPrefix+"/wapi/v"+version+"/", func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.URL.Query()["_schema"]; !ok || r.URL.Path != pathPrefix+"/wapi/v"+version+"/" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"requested_version":"` + version + `","supported_objects":` + objects + `,"supported_versions":["1.0","2.12.3","2.12","2.5"]}`))
+		})
+	}
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeServerCA writes the certificate of a test server to a file and returns its path.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caPath
+}
+
+func testConfig(t *testing.T, server *httptest.Server) Config {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{
+		HostConfig: HostConfig{
+			Host:         u.Hostname(),
+			Port:         u.Port(),
+			Version:      "2.12",
+			CustomCAPath: writeServerCA(t, server),
+		},
+		AuthConfig: AuthConfig{Username: "admin", Password: "secret"},
+	}
+}
+
+func TestClientUsesPortAndPathPrefix(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "/infoblox")
+	config := testConfig(t, server)
+	config.PathPrefix = "/infoblox"
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	g.Expect(c.CheckNetworkViewExists("other")).To(BeFalse())
+}
+
+func TestClientSupportsPortInHost(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	config.Host = net.JoinHostPort(config.Host, config.Port)
+	config.Port = ""
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+}
+
+func TestClientVerifiesCertificates(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	config.CustomCAPath = ""
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ContainSubstring("certificate")))
+
+	config.DisableTLSVerification = true
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+}
+
+func TestClientUsesCustomCAFromMemory(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	config.CustomCAPath = ""
+	config.CustomCA = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+
+	config.CustomCA = []byte("not a certificate")
+	_, err = NewClient(config)
+	g.Expect(err).To(MatchError(ContainSubstring("PEM")))
+}
+
+func TestClientUsesProxy(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+
+	// The proxy forwards all connections to the stand-in, so the grid can be addressed by a name that only the proxy can resolve.
+	// The certificate of the stand-in is valid for example.com.
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		proxied.Add(1)
+		upstream, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+
+	config.Host = "example.com"
+	config.ProxyURL = proxy.URL
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	g.Expect(proxied.Load()).To(BeNumerically(">", 0))
+}
+
+func TestProxyFunc(t *testing.T) {
+	g := NewWithT(t)
+
+	proxy, err := proxyFunc(HostConfig{ProxyURL: "http://proxy.example.com:3128", NoProxy: []string{"direct.example.com", "10.0.0.0/8"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for target, expected := range map[string]string{
+		"https://infoblox.example.com/wapi/v2.12/networkview": "http://proxy.example.com:3128",
+		"https://direct.example.com/wapi/v2.12/networkview":   "",
+		"https://10.1.2.3/wapi/v2.12/networkview":             "",
+	} {
+		req, err := http.NewRequest(http.MethodGet, target, http.NoBody)
+		g.Expect(err).NotTo(HaveOccurred())
+		u, err := proxy(req)
+		g.Expect(err).NotTo(HaveOccurred())
+		if expected == "" {
+			g.Expect(u).To(BeNil(), target)
+		} else {
+			g.Expect(u.String()).To(Equal(expected), target)
+		}
+	}
+
+	_, err = proxyFunc(HostConfig{ProxyURL: "://invalid"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestClientFailsOver(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	standIn := net.JoinHostPort(config.Host, config.Port)
+
+	// The primary endpoint refuses connections.
+	down := httptest.NewTLSServer(http.NotFoundHandler())
+	downURL, err := url.Parse(down.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	down.Close()
+	config.FailoverEndpoints = []Endpoint{{Host: config.Host, Port: config.Port}}
+	config.Port = downURL.Port()
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	info, err := c.GetGridInfo()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.Endpoint).To(Equal(standIn))
+
+	// The primary endpoint is in maintenance.
+	maintenance := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(maintenance.Close)
+	maintenanceURL, err := url.Parse(maintenance.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	config.Port = maintenanceURL.Port()
+
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	info, err = c.GetGridInfo()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.Endpoint).To(Equal(standIn))
+
+	// Requests are sent to the preferred endpoint first.
+	config.PreferredEndpoint = standIn
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.(*client).requestor.activeEndpoint()).To(Equal(standIn))
+
+	// Requests that modify objects are not sent to another endpoint after a gateway timeout, since they might have been applied.
+	var timeouts atomic.Int32
+	gateway := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		timeouts.Add(1)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	t.Cleanup(gateway.Close)
+	gatewayURL, err := url.Parse(gateway.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	config.Port = gatewayURL.Port()
+	config.PreferredEndpoint = ""
+
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	req, err := http.NewRequest(http.MethodPost, gateway.URL+"/wapi/v2.12/network", strings.NewReader(`{"network":"10.0.0.0/24"}`))
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.(*client).requestor.SendRequest(req)
+	g.Expect(err).To(MatchError(ContainSubstring("504")))
+	g.Expect(timeouts.Load()).To(BeEquivalentTo(1))
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	config.Port = maintenanceURL.Port()
+
+	// The error of the last endpoint is reported if all are unavailable.
+	config.FailoverEndpoints = nil
+	config.PreferredEndpoint = ""
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ContainSubstring("503")))
+}
+
+func TestClientRetries(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests atomic.Int32
+	statuses := []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusInternalServerError}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`"network/ZG5zLm5ldHdvcmskMTAuMC4wLjAvMjQvMA:10.0.0.0/24/default"`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"_ref":"networkview/ZG5zLm5ldHdvcmtfdmlldyQw:default/true","name":"default"}]`))
+	}))
+	t.Cleanup(server.Close)
+	config := testConfig(t, server)
+	config.Retry = RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	g.Expect(requests.Load()).To(BeEquivalentTo(4))
+
+	// Requests that modify objects are not retried on server errors, since they might have been applied.
+	requests.Store(int32(len(statuses) - 1))
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/wapi/v2.12/network", strings.NewReader(`{"network":"10.0.0.0/24"}`))
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.(*client).requestor.SendRequest(req)
+	g.Expect(err).To(MatchError(ContainSubstring("500")))
+	g.Expect(requests.Load()).To(BeEquivalentTo(len(statuses)))
+
+	// Retries without an initial backoff wait for the default backoff.
+	requests.Store(int32(len(statuses) - 1))
+	config.Retry = RetryConfig{MaxRetries: 1}
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	start := time.Now()
+	g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	g.Expect(time.Since(start)).To(BeNumerically(">=", DefaultRetryConfig.InitialBackoff))
+
+	// Retries can be disabled.
+	requests.Store(0)
+	config.Retry = RetryConfig{}
+	c, err = NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ContainSubstring("503")))
+	g.Expect(requests.Load()).To(BeEquivalentTo(2), "ibclient repeats failed requests once with _proxy_search=GM")
+}
+
+func TestClientRateLimitAndTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	server := newWAPIStandIn(t, "")
+	config := testConfig(t, server)
+	config.RateLimiter = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+
+	// The limiter is shared by all clients.
+	start := time.Now()
+	for range 3 {
+		c, err := NewClient(config)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(c.CheckNetworkViewExists("default")).To(BeTrue())
+	}
+	g.Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+
+	slow := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(slow.Close)
+	config = testConfig(t, slow)
+	config.RequestTimeout = 50 * time.Millisecond
+
+	c, err := NewClient(config)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CheckNetworkViewExists("default")
+	g.Expect(err).To(MatchError(ContainSubstring("Client.Timeout exceeded")))
+}
@@ -0,0 +1,48 @@
+package infoblox
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinimumWAPIVersion is the oldest WAPI version that supports all objects and functions used by the provider.
+var MinimumWAPIVersion = WAPIVersion{Major: 2, Minor: 5}
+
+// WAPIVersion is a version of the Infoblox WAPI, e.g. 2.12 or 2.12.3.
+type WAPIVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseWAPIVersion parses a WAPI version in the format used in WAPI URLs, without the leading 'v'.
+func ParseWAPIVersion(s string) (WAPIVersion, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return WAPIVersion{}, fmt.Errorf("invalid WAPI version %q: expected <major>.<minor>[.<patch>]", s)
+	}
+	numbers := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || p != strconv.Itoa(n) {
+			return WAPIVersion{}, fmt.Errorf("invalid WAPI version %q: %q is not a number", s, p)
+		}
+		numbers[i] = n
+	}
+	return WAPIVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older than, equal to or newer than other.
+func (v WAPIVersion) Compare(other WAPIVersion) int {
+	return cmp.Or(cmp.Compare(v.Major, other.Major), cmp.Compare(v.Minor, other.Minor), cmp.Compare(v.Patch, other.Patch))
+}
+
+// String returns the version in the format used in WAPI URLs.
+func (v WAPIVersion) String() string {
+	if v.Patch != 0 {
+		return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
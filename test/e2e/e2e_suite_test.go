@@ -0,0 +1,158 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox/fakewapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/komega"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// The e2e suite runs the provider binary against envtest, with the webhooks registered and a fake Infoblox WAPI.
+
+const (
+	operatorNamespace = "capi-ipam-infoblox-system"
+	wapiUsername      = "admin"
+	wapiPassword      = "infoblox"
+)
+
+var (
+	ctx       context.Context
+	cancelCtx func()
+	testEnv   *envtest.Environment
+	k8sClient client.Client
+	wapi      *fakewapi.Server
+	manager   *gexec.Session
+)
+
+func TestE2E(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "E2E Suite")
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+	ctx, cancelCtx = context.WithCancel(context.Background())
+	SetDefaultEventuallyTimeout(30 * time.Second)
+	SetDefaultEventuallyPollingInterval(250 * time.Millisecond)
+
+	By("starting the fake Infoblox WAPI")
+	wapi = fakewapi.NewServer(wapiUsername, wapiPassword)
+
+	By("bootstrapping test environment")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd", "bases"),
+			filepath.Join("..", "..", "config", "crd", "test"),
+		},
+		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "..", "config", "webhook")},
+		},
+		ControlPlaneStopTimeout:  60 * time.Second,
+		AttachControlPlaneOutput: true,
+	}
+	cfg, err := testEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+	Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	Expect(err).NotTo(HaveOccurred())
+	komega.SetClient(k8sClient)
+	komega.SetContext(ctx)
+
+	Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: operatorNamespace}})).To(Succeed())
+
+	By("building the provider")
+	binary, err := gexec.Build("github.com/telekom/cluster-api-ipam-provider-infoblox")
+	Expect(err).NotTo(HaveOccurred())
+
+	user, err := testEnv.AddUser(envtest.User{Name: "capi-ipam-infoblox", Groups: []string{"system:masters"}}, nil)
+	Expect(err).NotTo(HaveOccurred())
+	kubeconfig, err := user.KubeConfig()
+	Expect(err).NotTo(HaveOccurred())
+	kubeconfigPath := filepath.Join(GinkgoT().TempDir(), "kubeconfig")
+	Expect(os.WriteFile(kubeconfigPath, kubeconfig, 0o600)).To(Succeed())
+
+	By("starting the provider")
+	webhookOptions := testEnv.WebhookInstallOptions
+	cmd := exec.Command(binary,
+		"--webhook-port="+strconv.Itoa(webhookOptions.LocalServingPort),
+		"--webhook-cert-dir="+webhookOptions.LocalServingCertDir,
+		"--health-probe-bind-address=0",
+		"--diagnostics-address=0",
+		"--instance-health-check-interval=5s",
+	)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath, "NAMESPACE="+operatorNamespace)
+	manager, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("waiting for the webhook server")
+	webhookAddress := net.JoinHostPort(webhookOptions.LocalServingHost, strconv.Itoa(webhookOptions.LocalServingPort))
+	Eventually(func() error {
+		conn, err := tls.Dial("tcp", webhookAddress, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec // only checks that the server is up
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}).WithTimeout(time.Minute).Should(Succeed())
+})
+
+var _ = AfterSuite(func() {
+	cancelCtx()
+	if manager != nil {
+		By("stopping the provider")
+		manager.Terminate().Wait(30 * time.Second)
+	}
+	gexec.CleanupBuildArtifacts()
+
+	By("tearing down the test environment")
+	if testEnv != nil {
+		Expect(testEnv.Stop()).To(Succeed())
+	}
+	if wapi != nil {
+		wapi.Close()
+	}
+})
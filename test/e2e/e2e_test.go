@@ -0,0 +1,273 @@
+/*
+Copyright 2023 Deutsche Telekom AG.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"net/netip"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/api/v1alpha1"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/internal/webhooks"
+	"github.com/telekom/cluster-api-ipam-provider-infoblox/pkg/infoblox/fakewapi"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	. "sigs.k8s.io/controller-runtime/pkg/envtest/komega"
+)
+
+// hostRecordRefAnnotation is set on claims and addresses by the provider.
+const hostRecordRefAnnotation = "ipam.cluster.x-k8s.io/infoblox-host-record-ref"
+
+var _ = Describe("Infoblox IPAM provider", Ordered, func() {
+	const (
+		namespace    = "e2e"
+		instanceName = "e2e"
+		poolName     = "pool"
+		dnsPoolName  = "dns-pool"
+		dnsZone      = "cluster.example.com"
+	)
+
+	var (
+		instance v1alpha1.InfobloxInstance
+		pool     v1alpha1.InfobloxIPPool
+		dnsPool  v1alpha1.InfobloxIPPool
+	)
+
+	BeforeAll(func() {
+		wapi.AddNetwork(fakewapi.DefaultNetworkView, netip.MustParsePrefix("10.0.0.0/24"), nil)
+		wapi.AddNetwork(fakewapi.DefaultNetworkView, netip.MustParsePrefix("10.0.1.0/24"), nil)
+
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "infoblox-credentials", Namespace: operatorNamespace},
+			Type:       corev1.SecretTypeBasicAuth,
+			StringData: map[string]string{
+				corev1.BasicAuthUsernameKey: wapiUsername,
+				corev1.BasicAuthPasswordKey: wapiPassword,
+			},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "infoblox-ca", Namespace: operatorNamespace},
+			Data:       map[string]string{v1alpha1.DefaultCABundleKey: string(wapi.CACertificate())},
+		})).To(Succeed())
+
+		instance = v1alpha1.InfobloxInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: instanceName},
+			Spec: v1alpha1.InfobloxInstanceSpec{
+				Host:                 wapi.Host(),
+				Port:                 wapi.Port(),
+				CredentialsSecretRef: v1alpha1.CredentialsReferece{Name: "infoblox-credentials"},
+				CABundleRef:          v1alpha1.CABundleReference{Kind: v1alpha1.CABundleKindConfigMap, Name: "infoblox-ca"},
+			},
+		}
+		pool = newPool(poolName, namespace, instanceName, v1alpha1.Subnet{CIDR: "10.0.0.0/24", Gateway: "10.0.0.254"}, "")
+		dnsPool = newPool(dnsPoolName, namespace, instanceName, v1alpha1.Subnet{CIDR: "10.0.1.0/24", Gateway: "10.0.1.254"}, dnsZone)
+	})
+
+	AfterAll(func() {
+		claims := &ipamv1.IPAddressClaimList{}
+		Expect(k8sClient.List(ctx, claims, client.InNamespace(namespace))).To(Succeed())
+		for i := range claims.Items {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &claims.Items[i]))).To(Succeed())
+		}
+		Eventually(ObjectList(&ipamv1.IPAddressList{}, client.InNamespace(namespace))).Should(HaveField("Items", BeEmpty()))
+
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &pool))).To(Succeed())
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &dnsPool))).To(Succeed())
+		Eventually(ObjectList(&v1alpha1.InfobloxIPPoolList{}, client.InNamespace(namespace))).Should(HaveField("Items", BeEmpty()))
+
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &instance))).To(Succeed())
+		Eventually(Get(&instance)).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("rejects invalid instances", func() {
+		invalid := instance.DeepCopy()
+		invalid.Name = "invalid"
+		invalid.Spec.Host = "https://" + wapi.Host()
+		invalid.Spec.Port = "70000"
+
+		err := k8sClient.Create(ctx, invalid)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue(), "expected an invalid error, got %v", err)
+		Expect(err).To(MatchError(And(
+			ContainSubstring("host must not contain a scheme or path"),
+			ContainSubstring("port must be a number between 1 and 65535"),
+		)))
+	})
+
+	It("marks the instance ready once it connected to Infoblox", func() {
+		Expect(k8sClient.Create(ctx, &instance)).To(Succeed())
+
+		Eventually(Object(&instance)).Should(HaveField("Status.Conditions", ContainElement(And(
+			HaveField("Type", clusterv1.ReadyCondition),
+			HaveField("Status", metav1.ConditionTrue),
+		))))
+	})
+
+	It("marks pools ready whose subnets exist in Infoblox", func() {
+		Expect(k8sClient.Create(ctx, &pool)).To(Succeed())
+		Expect(k8sClient.Create(ctx, &dnsPool)).To(Succeed())
+		missing := newPool("missing-subnet", namespace, instanceName, v1alpha1.Subnet{CIDR: "10.9.0.0/24", Gateway: "10.9.0.254"}, "")
+		Expect(k8sClient.Create(ctx, &missing)).To(Succeed())
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(ctx, &missing)).To(Succeed())
+			Eventually(Get(&missing)).Should(Satisfy(apierrors.IsNotFound))
+		})
+
+		for _, p := range []*v1alpha1.InfobloxIPPool{&pool, &dnsPool} {
+			Eventually(Object(p)).Should(HaveField("Status.Conditions", ContainElement(And(
+				HaveField("Type", clusterv1.ReadyCondition),
+				HaveField("Status", metav1.ConditionTrue),
+			))))
+		}
+		Eventually(Object(&missing)).Should(HaveField("Status.Conditions", ContainElement(And(
+			HaveField("Type", clusterv1.ReadyCondition),
+			HaveField("Status", metav1.ConditionFalse),
+			HaveField("Reason", v1alpha1.NetworkNotFoundReason),
+		))))
+	})
+
+	It("allocates addresses for claims", func() {
+		claim := newClaim("claim", namespace, poolName)
+		Expect(k8sClient.Create(ctx, &claim)).To(Succeed())
+
+		address := ipamv1.IPAddress{ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: namespace}}
+		Eventually(Object(&address)).Should(SatisfyAll(
+			HaveField("Spec.Address", "10.0.0.1"),
+			HaveField("Spec.Prefix", HaveValue(BeEquivalentTo(24))),
+			HaveField("Spec.Gateway", "10.0.0.254"),
+		))
+		Expect(wapi.HostRecordAddresses(claim.Name)).To(ConsistOf("10.0.0.1"))
+		Eventually(Object(&claim)).Should(HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, Not(BeEmpty()))))
+
+		second := newClaim("second-claim", namespace, poolName)
+		Expect(k8sClient.Create(ctx, &second)).To(Succeed())
+		Eventually(Object(&ipamv1.IPAddress{ObjectMeta: metav1.ObjectMeta{Name: second.Name, Namespace: namespace}})).Should(
+			HaveField("Spec.Address", "10.0.0.2"))
+	})
+
+	It("creates DNS records for claims from pools with a DNS zone", func() {
+		claim := newClaim("dns-claim", namespace, dnsPoolName)
+		claim.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Machine",
+			Name:       "machine-0",
+			UID:        types.UID("4a8d2c1e-5d0b-4d8e-9f4a-0c6d4b1f2e3a"),
+		}}
+		Expect(k8sClient.Create(ctx, &claim)).To(Succeed())
+
+		Eventually(Object(&ipamv1.IPAddress{ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: namespace}})).Should(
+			HaveField("Spec.Address", "10.0.1.1"))
+		Expect(wapi.Objects("record:host")).To(ContainElement(SatisfyAll(
+			HaveKeyWithValue("name", "machine-0."+dnsZone),
+			HaveKeyWithValue("configure_for_dns", true),
+			HaveKeyWithValue("view", fakewapi.DefaultDNSView),
+		)))
+	})
+
+	It("protects pools and instances that are in use from deletion", func() {
+		err := k8sClient.Delete(ctx, pool.DeepCopy(), client.DryRunAll)
+		Expect(err).To(MatchError(ContainSubstring("Pool has IPAddresses allocated")))
+
+		err = k8sClient.Delete(ctx, instance.DeepCopy(), client.DryRunAll)
+		Expect(err).To(MatchError(ContainSubstring("Instance is referenced by pools")))
+	})
+
+	It("lets clusterctl move delete pools and instances annotated to skip the delete validation", func() {
+		annotated := pool.DeepCopy()
+		Expect(Update(annotated, func() {
+			annotated.Annotations = map[string]string{webhooks.SkipValidateDeleteWebhookAnnotation: ""}
+		})()).To(Succeed())
+		Expect(k8sClient.Delete(ctx, annotated, client.DryRunAll)).To(Succeed())
+
+		annotatedInstance := instance.DeepCopy()
+		Expect(Update(annotatedInstance, func() {
+			annotatedInstance.Annotations = map[string]string{webhooks.SkipValidateDeleteWebhookAnnotation: ""}
+		})()).To(Succeed())
+		Expect(k8sClient.Delete(ctx, annotatedInstance, client.DryRunAll)).To(Succeed())
+
+		// The dry run must not have released anything.
+		Expect(wapi.HostRecordAddresses("claim")).To(ConsistOf("10.0.0.1"))
+	})
+
+	It("keeps the host records of claims moved by clusterctl move and adopts them on the target", func() {
+		moved := ipamv1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{Name: "second-claim", Namespace: namespace}}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&moved), &moved)).To(Succeed())
+		ref := moved.Annotations[hostRecordRefAnnotation]
+		Expect(ref).NotTo(BeEmpty())
+
+		// clusterctl move recreates the claim with its annotations in the target cluster
+		// and deletes it from the source cluster with the delete-for-move annotation.
+		target := newClaim(moved.Name, namespace, poolName)
+		target.Annotations = moved.Annotations
+
+		Expect(Update(&moved, func() {
+			moved.Annotations[clusterctlv1.DeleteForMoveAnnotation] = ""
+		})()).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &moved)).To(Succeed())
+		Eventually(Get(&moved)).Should(Satisfy(apierrors.IsNotFound))
+		Consistently(func() []string { return wapi.HostRecordAddresses(moved.Name) }).
+			WithTimeout(2 * time.Second).Should(ConsistOf("10.0.0.2"))
+
+		Expect(k8sClient.Create(ctx, &target)).To(Succeed())
+		Eventually(Object(&ipamv1.IPAddress{ObjectMeta: metav1.ObjectMeta{Name: target.Name, Namespace: namespace}})).Should(SatisfyAll(
+			HaveField("Spec.Address", "10.0.0.2"),
+			HaveField("Annotations", HaveKeyWithValue(hostRecordRefAnnotation, ref)),
+		))
+		Expect(wapi.HostRecordAddresses(target.Name)).To(ConsistOf("10.0.0.2"))
+	})
+
+	It("releases the host records of deleted claims", func() {
+		claim := ipamv1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: namespace}}
+		Expect(k8sClient.Delete(ctx, &claim)).To(Succeed())
+
+		Eventually(Get(&ipamv1.IPAddress{ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: namespace}})).Should(Satisfy(apierrors.IsNotFound))
+		Eventually(func() []string { return wapi.HostRecordAddresses(claim.Name) }).Should(BeEmpty())
+		Expect(wapi.HostRecordAddresses("second-claim")).To(ConsistOf("10.0.0.2"))
+	})
+})
+
+func newPool(name, namespace, instanceName string, subnet v1alpha1.Subnet, dnsZone string) v1alpha1.InfobloxIPPool {
+	return v1alpha1.InfobloxIPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1alpha1.InfobloxIPPoolSpec{
+			InstanceRef: v1alpha1.InstanceReference{Name: instanceName},
+			Subnets:     []v1alpha1.Subnet{subnet},
+			NetworkView: fakewapi.DefaultNetworkView,
+			DNSZone:     dnsZone,
+		},
+	}
+}
+
+func newClaim(name, namespace, poolName string) ipamv1.IPAddressClaim {
+	return ipamv1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: ipamv1.IPAddressClaimSpec{
+			PoolRef: ipamv1.IPPoolReference{
+				APIGroup: v1alpha1.GroupVersion.Group,
+				Kind:     "InfobloxIPPool",
+				Name:     poolName,
+			},
+		},
+	}
+}